@@ -9,6 +9,7 @@ import (
 	"encoding/xml"
 	"io/ioutil"
 	"fmt"
+	"errors"
 )
 
 type Result struct {
@@ -50,11 +51,25 @@ to quickly create a Cobra application.`,
 		//proxy, err := cmd.Flags().GetString("proxy")
 		proxyUser, err := cmd.Flags().GetString("proxy-user")
 		proxyPass, err := cmd.Flags().GetString("proxy-pass")
+		shuffle, err := cmd.Flags().GetBool("shuffle")
+		seed, err := cmd.Flags().GetInt64("seed")
+		protocol, err := cmd.Flags().GetString("protocol")
+		conns, err := cmd.Flags().GetUint("conns")
+		streams, err := cmd.Flags().GetUint("streams")
 		if err != nil {
 			return err
 		}
 
+		proto, err := lib.ParseProtocol(protocol)
+		if err != nil {
+			return err
+		}
+		if conns == 0 || streams == 0 {
+			return errors.New("Number of connections and streams must be positive")
+		}
+
 		disp := lib.NewDispatcher(timeout)
+		disp.Seeder = lib.NewSeeder(seed)
 
 		options := lib.LoadEmitterOptions{
 			Ip:   ip,
@@ -83,6 +98,12 @@ to quickly create a Cobra application.`,
 			var s Result
 			b, _ := ioutil.ReadAll(f)
 			xml.Unmarshal(b, &s)
+			if shuffle {
+				rnd := disp.Seeder.Rand()
+				rnd.Shuffle(len(s.For.Request), func(i, j int) {
+					s.For.Request[i], s.For.Request[j] = s.For.Request[j], s.For.Request[i]
+				})
+			}
 			options.Urls = make(chan string, len(s.For.Request))
 			for _, req := range s.For.Request {
 				options.Urls <- req.Http.Url
@@ -92,8 +113,22 @@ to quickly create a Cobra application.`,
 			return err
 		}
 
+		// workers of http/2 and http/3 emitters share connections
+		var pool *lib.Pool
+		switch proto {
+		case lib.H2, lib.H2C:
+			pool = lib.NewH2Pool(ps.Host, proto, int(conns), int(streams))
+		case lib.H3:
+			pool = lib.NewH3Pool(ps.Host, int(conns), int(streams))
+		}
+
 		for i := uint(0); i < workers; i++ {
-			emitter := lib.NewLoadEmitter(&options, ps)
+			var emitter lib.Emitter
+			if pool != nil {
+				emitter = lib.NewMultiplexLoadEmitter(&options, pool, ps)
+			} else {
+				emitter = lib.NewLoadEmitter(&options, ps)
+			}
 			disp.Emitters = append(disp.Emitters, emitter)
 		}
 
@@ -110,6 +145,12 @@ func init() {
 	loadCmd.Flags().IntP("timeout", "t", 0, "Maximum test duration(0=endless)")
 	loadCmd.Flags().StringP("ip", "i", "", "Destination IP address")
 	loadCmd.Flags().StringP("port", "p", "8080", "Destination port")
+	loadCmd.Flags().Bool("shuffle", false, "Shuffle url list before sending requests")
+	loadCmd.Flags().Int64("seed", 0, "Seed of url list shuffle, use the one printed in the summary to replay a run, 0 = random")
+
+	loadCmd.Flags().String("protocol", string(lib.HTTP11), "Protocol requests are sent with [http/1.1,h2,h2c,h3]")
+	loadCmd.Flags().Uint("conns", 1, "Number of http/2 or http/3 connections requests of all workers are multiplexed over")
+	loadCmd.Flags().Uint("streams", 100, "Max number of concurrent streams per http/2 or http/3 connection")
 
 	loadCmd.Flags().String("proxy", "", "Proxy server url. Can contain basic proxy authentication.")
 	loadCmd.Flags().String("proxy-user", "", "Proxy user login")
@@ -20,9 +20,27 @@ import (
 	"github.com/spf13/cobra"
 	"net/http"
 	"github.com/spf13/viper"
+	"encoding/json"
 	"errors"
 	"github.com/pupizoid/fatty/lib"
 	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"strings"
+	"time"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
 )
 
 // serverCmd represents the server command
@@ -39,6 +57,15 @@ func init() {
 
 	serverCmd.Flags().String("ip", "127.0.0.1", "Listen ip address")
 	serverCmd.Flags().Int("port", 3128, "Listen port")
+	serverCmd.Flags().Duration("read-timeout", 0, "Max time of reading the whole request, 0 = unlimited")
+	serverCmd.Flags().Duration("read-header-timeout", 0, "Max time of reading request header, 0 = the same as read timeout")
+	serverCmd.Flags().Duration("idle-timeout", 0, "Max time idle keep-alive connection is kept open, 0 = the same as read timeout")
+	serverCmd.Flags().Int("max-conns", 0, "Max number of connections served at once, the rest wait in the queue, 0 = unlimited")
+	serverCmd.Flags().Uint32("max-streams", 0, "Max number of concurrent http/2 streams per connection, 0 = 250")
+	serverCmd.Flags().String("cert-file", "", "Certificate file, server speaks https and h2 if it's set, h2c otherwise")
+	serverCmd.Flags().String("key-file", "", "Private key file of the certificate")
+	serverCmd.Flags().Int64("ws-read-limit", 0, "Max size of websocket message, bigger ones are closed with 1009 code, 0 = unlimited")
+	serverCmd.Flags().Bool("h3", false, "Also serve http/3 on the same udp port, with self-signed certificate if no certificate file is set")
 
 	// Here you will define your flags and configuration settings.
 
@@ -56,10 +83,25 @@ func serve(c *cobra.Command, args []string) (err error) {
 
 	var ip, addr string
 	var port int
+	var readTimeout, readHeaderTimeout, idleTimeout time.Duration
+	var maxConns int
+	var maxStreams uint32
+	var certFile, keyFile string
+	var h3 bool
+	var wsReadLimit int64
 
 	if viper.ConfigFileUsed() != "" {
 		port = viper.GetInt("server.port")
 		ip = viper.GetString("server.ip")
+		readTimeout = viper.GetDuration("server.read-timeout")
+		readHeaderTimeout = viper.GetDuration("server.read-header-timeout")
+		idleTimeout = viper.GetDuration("server.idle-timeout")
+		maxConns = viper.GetInt("server.max-conns")
+		maxStreams = viper.GetUint32("server.max-streams")
+		certFile = viper.GetString("server.cert-file")
+		keyFile = viper.GetString("server.key-file")
+		h3 = viper.GetBool("server.h3")
+		wsReadLimit = viper.GetInt64("server.ws-read-limit")
 	} else {
 		if ip, err = c.Flags().GetString("ip"); err != nil {
 			return
@@ -67,6 +109,33 @@ func serve(c *cobra.Command, args []string) (err error) {
 		if port, err = c.Flags().GetInt("port"); err != nil {
 			return
 		}
+		if readTimeout, err = c.Flags().GetDuration("read-timeout"); err != nil {
+			return
+		}
+		if readHeaderTimeout, err = c.Flags().GetDuration("read-header-timeout"); err != nil {
+			return
+		}
+		if idleTimeout, err = c.Flags().GetDuration("idle-timeout"); err != nil {
+			return
+		}
+		if maxConns, err = c.Flags().GetInt("max-conns"); err != nil {
+			return
+		}
+		if maxStreams, err = c.Flags().GetUint32("max-streams"); err != nil {
+			return
+		}
+		if certFile, err = c.Flags().GetString("cert-file"); err != nil {
+			return
+		}
+		if keyFile, err = c.Flags().GetString("key-file"); err != nil {
+			return
+		}
+		if h3, err = c.Flags().GetBool("h3"); err != nil {
+			return
+		}
+		if wsReadLimit, err = c.Flags().GetInt64("ws-read-limit"); err != nil {
+			return
+		}
 	}
 
 	switch {
@@ -78,22 +147,176 @@ func serve(c *cobra.Command, args []string) (err error) {
 		return errors.New("Unsupported ip & port combination")
 	}
 
-	http.HandleFunc("/", handler)
+	if (certFile == "") != (keyFile == "") {
+		return errors.New("Both certificate and key files must be set")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			wsEcho(w, r, wsReadLimit)
+			return
+		}
+		handler(w, r)
+	})
+	h2Server := &http2.Server{MaxConcurrentStreams: maxStreams}
+	server := &http.Server{
+		Addr:              addr,
+		// plain connections are upgraded to h2c if client asks for it
+		Handler:           h2c.NewHandler(http.DefaultServeMux, h2Server),
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	if err = http2.ConfigureServer(server, h2Server); err != nil {
+		return
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+	if maxConns > 0 {
+		listener = netutil.LimitListener(listener, maxConns)
+	}
+	if h3 {
+		var tlsConfig *tls.Config
+		if tlsConfig, err = serverTLSConfig(certFile, keyFile); err != nil {
+			return
+		}
+		h3Server := &http3.Server{
+			Addr:        addr,
+			Handler:     http.DefaultServeMux,
+			TLSConfig:   http3.ConfigureTLSConfig(tlsConfig),
+			IdleTimeout: idleTimeout,
+		}
+		go func() {
+			if err := h3Server.ListenAndServe(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+		fmt.Printf("Starting http/3 server on %s:%d\n", ip, port)
+	}
 	fmt.Printf("Starting server on %s:%d\n", ip, port)
-	if err = http.ListenAndServe(addr, nil); err != nil {
+	if certFile != "" {
+		err = server.ServeTLS(listener, certFile, keyFile)
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil {
 		fmt.Println(err)
 		return
 	}
 	return
 }
 
+// serverTLSConfig loads certificate from files or generates self-signed one if they are not set
+func serverTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fatty"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+// wsEcho sends every websocket message back until the client closes connection
+func wsEcho(w http.ResponseWriter, r *http.Request, readLimit int64) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer conn.Close()
+	if readLimit > 0 {
+		conn.SetReadLimit(readLimit)
+	}
+	for {
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		fmt.Printf("websocket message len: %d\n", len(p))
+		if err = conn.WriteMessage(messageType, p); err != nil {
+			return
+		}
+	}
+}
+
+// the same amount net/http uses by default, bigger files are stored on disk
+const multipartMaxMemory = 32 << 20
+
 func handler(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("header len: %d\n", len(r.Header.Get(lib.RequestHeaderName)))
-	p, _ := ioutil.ReadAll(r.Body)
-	r.Body.Close()
-	fmt.Printf("body len: %d\n", len(p))
+	if seq := r.Header.Get(lib.PipelineSeqHeader); seq != "" {
+		w.Header().Set(lib.PipelineSeqHeader, seq)
+	}
+
+	echo := lib.Echo{Cookies: make(map[string]int), Query: make(map[string]int), Parts: make(map[string]int)}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(multipartMaxMemory); err != nil {
+			code := http.StatusBadRequest
+			if errors.Is(err, multipart.ErrMessageTooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		for name, values := range r.MultipartForm.Value {
+			echo.Parts[name] = len(values[0])
+		}
+		for name, files := range r.MultipartForm.File {
+			echo.Parts[name] = int(files[0].Size)
+		}
+		fmt.Printf("multipart parts: %d\n", len(echo.Parts))
+	} else {
+		p, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		fmt.Printf("body len: %d\n", len(p))
+		if err != nil {
+			code := http.StatusBadRequest
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				code = http.StatusRequestTimeout
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		echo.Body = len(p)
+	}
 
 	fmt.Printf("%#v\n", r)
 	fmt.Printf("%#v\n", r.URL)
 
+	for _, cookie := range r.Cookies() {
+		echo.Cookies[cookie.Name] = len(cookie.Value)
+	}
+	for name, values := range r.URL.Query() {
+		echo.Query[name] = len(values[0])
+	}
+	w.Header().Set("Content-Type", lib.EchoContentType)
+	json.NewEncoder(w).Encode(echo)
 }
\ No newline at end of file
@@ -1,134 +1,632 @@
-//// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
-////
-//// Licensed under the Apache License, Version 2.0 (the "License");
-//// you may not use this file except in compliance with the License.
-//// You may obtain a copy of the License at
-////
-////     http://www.apache.org/licenses/LICENSE-2.0
-////
-//// Unless required by applicable law or agreed to in writing, software
-//// distributed under the License is distributed on an "AS IS" BASIS,
-//// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-//// See the License for the specific language governing permissions and
-//// limitations under the License.
-//
-package cmd
-//
-//import (
-//	"github.com/spf13/cobra"
-//	"net/http"
-//	"github.com/pupizoid/fatty/lib"
-//	"net/url"
-//	"strings"
-//)
-//
-//// testCmd represents the test command
-//var testCmd = &cobra.Command{
-//	Use:   "test",
-//	Short: "Runs a test of desired web server or proxy",
-//	Long: `A longer description that spans multiple lines and likely contains examples
-//and usage of using your command. For example:
-//
-//Cobra is a CLI library for Go that empowers applications.
-//This application is a tool to generate the needed files
-//to quickly create a Cobra application.`,
-//	RunE: func(cmd *cobra.Command, args []string) (err error) {
-//
-//		dest, err := cmd.Flags().GetString("dest")
-//		workers, err := cmd.Flags().GetUint("workers")
-//		limit, err := cmd.Flags().GetUint32("limit")
-//		method, err := cmd.Flags().GetString("method")
-//
-//		headerSize, err := cmd.Flags().GetUint("header-size")
-//		headerInc, err := cmd.Flags().GetUint("header-inc-rate")
-//		headerMulti, err := cmd.Flags().GetUint("header-multi-rate")
-//
-//		bodySize, err := cmd.Flags().GetUint("body-size")
-//		bodyInc, err := cmd.Flags().GetUint("body-inc-rate")
-//		bodyMulti, err := cmd.Flags().GetUint("body-multi-rate")
-//		bodyFile, err := cmd.Flags().GetString("body-from-file")
-//
-//		proxy, err := cmd.Flags().GetString("proxy")
-//		proxyUser, err := cmd.Flags().GetString("proxy-user")
-//		proxyPass, err := cmd.Flags().GetString("proxy-pass")
-//
-//		if err != nil {return}
-//
-//		disp := lib.NewDispatcher()
-//
-//		ds, err := url.Parse(dest)
-//		if err != nil {
-//			return
-//		}
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
 //
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
 //
-//		var header lib.GrowableContent
-//		if headerSize > 0 {
-//			header = lib.NewContent(headerSize, headerInc, headerMulti)
-//		}
+//     http://www.apache.org/licenses/LICENSE-2.0
 //
-//		var body lib.GrowableContent
-//		if bodyFile == "" {
-//			if bodySize > 0 {
-//				body = lib.NewContent(bodySize, bodyInc, bodyMulti)
-//			}
-//		} else {
-//			body, err = lib.NewBodyFromFile(bodyFile)
-//			if err != nil {
-//				return
-//			}
-//		}
-//
-//		var ps *url.URL
-//		if proxy != "" {
-//			if !strings.Contains(proxy, "http://") && !strings.Contains(proxy, "https://") {
-//				proxy = "http://" + proxy // support only http proxy for now
-//			}
-//
-//			ps, err = lib.ParseProxy(proxy)
-//			if err != nil {
-//				return err
-//			}
-//
-//			if proxyUser != "" {
-//				if proxyPass != "" {
-//					ps.User = url.UserPassword(proxyUser, proxyPass)
-//				} else {
-//					ps.User = url.User(proxyUser)
-//				}
-//			}
-//		}
-//
-//		for i := 0; uint(i) < workers; i++ {
-//			disp.Emitters = append(disp.Emitters, lib.NewEmitter(
-//				method, limit, header, body, ds, ps,
-//			))
-//		}
-//
-//		disp.Run()
-//
-//		return
-//	},
-//}
-//
-//func init() {
-//	RootCmd.AddCommand(testCmd)
-//
-//	testCmd.Flags().StringP("dest", "d", "", "Requests destination")
-//	testCmd.Flags().UintP("workers", "w", 1, "Workers count (async testing)")
-//	testCmd.Flags().Uint32P("limit", "l", 0, "Count of requests to be sent, 0 = unlimited")
-//	testCmd.Flags().StringP("method", "m", http.MethodGet, "Request method")
-//
-//	testCmd.Flags().Uint("header-size", 0, "Request header size (bytes)")
-//	testCmd.Flags().Uint("header-inc-rate", 0, "Request header amplification rate (bytes)")
-//	testCmd.Flags().Uint("header-multi-rate", 1, "Request header multiplication rate")
-//
-//	testCmd.Flags().UintP("body-size", "b", 0, "Request body size (bytes)")
-//	testCmd.Flags().Uint("body-inc-rate", 0, "Request body amplification rate (bytes)")
-//	testCmd.Flags().Uint("body-multi-rate", 1, "Request body multiplication rate")
-//	testCmd.Flags().String("body-from-file", "", "Read request body content from file")
-//
-//	testCmd.Flags().StringP("proxy", "p", "", "Proxy server url. Can contain basic proxy authentication.")
-//	testCmd.Flags().String("proxy-user", "", "Proxy user login")
-//	testCmd.Flags().String("proxy-pass", "", "Proxy user password")
-//}
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pupizoid/fatty/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Searches for max request sizes accepted by web server or proxy",
+	Long: `Grows probed part of the request until the server rejects it, then bisects
+between the last accepted and the first rejected sizes to find the exact limit.
+
+Example:
+
+  fatty test --header -d http://127.0.0.1:3128/
+  fatty test --body --max-size 1073741824 -d http://127.0.0.1:3128/upload`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		dest, err := cmd.Flags().GetString("dest")
+		limit, err := cmd.Flags().GetUint32("limit")
+		method, err := cmd.Flags().GetString("method")
+		timeout, err := cmd.Flags().GetInt("timeout")
+		requestTimeout, err := cmd.Flags().GetDuration("request-timeout")
+		verdicts, err := cmd.Flags().GetString("verdicts")
+		retries, err := cmd.Flags().GetInt("retries")
+		seed, err := cmd.Flags().GetInt64("seed")
+
+		testHeader, err := cmd.Flags().GetBool("header")
+		headerSize, err := cmd.Flags().GetUint("header-size")
+		headerInc, err := cmd.Flags().GetUint("header-inc-rate")
+		headerMulti, err := cmd.Flags().GetUint("header-multi-rate")
+		headerModesFlag, err := cmd.Flags().GetString("header-modes")
+		headerNames, err := cmd.Flags().GetStringSlice("header-names")
+
+		testBody, err := cmd.Flags().GetBool("body")
+		bodySize, err := cmd.Flags().GetUint("body-size")
+		bodyInc, err := cmd.Flags().GetUint("body-inc-rate")
+		bodyMulti, err := cmd.Flags().GetUint("body-multi-rate")
+		bodyFile, err := cmd.Flags().GetString("body-from-file")
+		bodyStream, err := cmd.Flags().GetBool("body-stream")
+		chunked, err := cmd.Flags().GetBool("chunked")
+		chunkSizes, err := cmd.Flags().GetUintSlice("chunk-sizes")
+		bodyModesFlag, err := cmd.Flags().GetString("body-modes")
+		maxSize, err := cmd.Flags().GetInt("max-size")
+
+		testURI, err := cmd.Flags().GetBool("uri")
+		uriSize, err := cmd.Flags().GetUint("uri-size")
+		uriInc, err := cmd.Flags().GetUint("uri-inc-rate")
+		uriMulti, err := cmd.Flags().GetUint("uri-multi-rate")
+
+		testHeaderCount, err := cmd.Flags().GetBool("header-count")
+		headerValueSize, err := cmd.Flags().GetUint("header-value-size")
+		countSize, err := cmd.Flags().GetUint("count-size")
+		countInc, err := cmd.Flags().GetUint("count-inc-rate")
+		countMulti, err := cmd.Flags().GetUint("count-multi-rate")
+
+		testCookieCount, err := cmd.Flags().GetBool("cookie-count")
+		testCookieSize, err := cmd.Flags().GetBool("cookie-size")
+		cookieValueSize, err := cmd.Flags().GetUint("cookie-value-size")
+		cookies, err := cmd.Flags().GetUint("cookies")
+		cookieModesFlag, err := cmd.Flags().GetString("cookie-modes")
+
+		testMultipartParts, err := cmd.Flags().GetBool("multipart-parts")
+		partValueSize, err := cmd.Flags().GetUint("part-value-size")
+		testMultipartFile, err := cmd.Flags().GetBool("multipart-file")
+		testMultipartTotal, err := cmd.Flags().GetBool("multipart-total")
+		multipartFiles, err := cmd.Flags().GetUint("multipart-files")
+
+		testGrid, err := cmd.Flags().GetBool("grid")
+		gridHeaderMax, err := cmd.Flags().GetInt("grid-header-max")
+		gridBodyMax, err := cmd.Flags().GetInt("grid-body-max")
+		gridOutput, err := cmd.Flags().GetString("grid-output")
+		gridFormat, err := cmd.Flags().GetString("grid-format")
+
+		testSlowBody, err := cmd.Flags().GetBool("slow-body")
+		slowBodySizes, err := cmd.Flags().GetUintSlice("slow-body-sizes")
+		testSlowHeader, err := cmd.Flags().GetBool("slow-header")
+		slowHeaderLines, err := cmd.Flags().GetUint("slow-header-lines")
+		slowStart, err := cmd.Flags().GetDuration("slow-start")
+		slowInc, err := cmd.Flags().GetDuration("slow-inc-rate")
+		slowMulti, err := cmd.Flags().GetUint("slow-multi-rate")
+		slowMax, err := cmd.Flags().GetDuration("slow-max")
+
+		testKeepAliveIdle, err := cmd.Flags().GetBool("keepalive-idle")
+		testKeepAliveRequests, err := cmd.Flags().GetBool("keepalive-requests")
+		keepAliveMaxIdle, err := cmd.Flags().GetDuration("max-idle")
+		keepAliveMaxRequests, err := cmd.Flags().GetInt("max-conn-requests")
+
+		testPipeline, err := cmd.Flags().GetBool("pipeline")
+
+		testConns, err := cmd.Flags().GetBool("conns")
+		maxConns, err := cmd.Flags().GetInt("max-conns")
+		queueDelay, err := cmd.Flags().GetDuration("queue-delay")
+
+		protocolNames, err := cmd.Flags().GetStringSlice("protocols")
+		testH2Limits, err := cmd.Flags().GetBool("h2-limits")
+		h2MaxStreams, err := cmd.Flags().GetInt("h2-max-streams")
+
+		testWSMessage, err := cmd.Flags().GetBool("ws-message")
+		testWSFrame, err := cmd.Flags().GetBool("ws-frame")
+		wsFrameSize, err := cmd.Flags().GetUint("ws-frame-size")
+		testWSConns, err := cmd.Flags().GetBool("ws-conns")
+
+		testQueryCount, err := cmd.Flags().GetBool("query-count")
+		queryValueSize, err := cmd.Flags().GetUint("query-value-size")
+
+		strategy, err := cmd.Flags().GetString("strategy")
+		scheduleFile, err := cmd.Flags().GetString("schedule-file")
+
+		proxy, err := cmd.Flags().GetString("proxy")
+		proxyUser, err := cmd.Flags().GetString("proxy-user")
+		proxyPass, err := cmd.Flags().GetString("proxy-pass")
+
+		if err != nil {
+			return
+		}
+
+		if viper.ConfigFileUsed() != "" {
+			strategy = configString("test.strategy", strategy)
+			scheduleFile = configString("test.schedule-file", scheduleFile)
+			headerSize = configUint("test.header.size", headerSize)
+			headerInc = configUint("test.header.inc-rate", headerInc)
+			headerMulti = configUint("test.header.multi-rate", headerMulti)
+			bodySize = configUint("test.body.size", bodySize)
+			bodyInc = configUint("test.body.inc-rate", bodyInc)
+			bodyMulti = configUint("test.body.multi-rate", bodyMulti)
+		}
+
+		headerModes, err := lib.ParseHeaderModes(headerModesFlag)
+		if err != nil {
+			return
+		}
+		cookieModes, err := lib.ParseCookieModes(cookieModesFlag)
+		if err != nil {
+			return
+		}
+		bodyModes, err := lib.ParseModes(bodyModesFlag)
+		if err != nil {
+			return
+		}
+		protos, err := lib.ParseProtocols(protocolNames)
+		if err != nil {
+			return
+		}
+
+		if !testHeader && !testBody && !testHeaderCount && !testURI && !testCookieCount && !testCookieSize && !testQueryCount &&
+			!testMultipartParts && !testMultipartFile && !testMultipartTotal && !testGrid && !testSlowBody && !testSlowHeader &&
+			!testKeepAliveIdle && !testKeepAliveRequests && !testConns && !testPipeline && !testH2Limits &&
+			!testWSMessage && !testWSFrame && !testWSConns {
+			return errors.New("Nothing to test, choose at least one of: --header, --body, --header-count, --uri, --cookie-count, --cookie-size, --query-count, --multipart-parts, --multipart-file, --multipart-total, --grid, --slow-body, --slow-header, --keepalive-idle, --keepalive-requests, --conns, --pipeline, --h2-limits, --ws-message, --ws-frame, --ws-conns")
+		}
+		if gridFormat != "csv" && gridFormat != "json" {
+			return errors.New("Grid format must be either csv or json")
+		}
+		if testMultipartTotal && multipartFiles == 0 {
+			return errors.New("Multipart total size probe needs at least one file")
+		}
+		if testWSMessage && wsFrameSize == 0 {
+			return errors.New("Websocket frame size must be positive")
+		}
+		if testCookieSize && cookies == 0 {
+			return errors.New("Cookie size probe needs at least one cookie")
+		}
+
+		ds, err := url.Parse(dest)
+		if err != nil {
+			return
+		}
+		if ds.Host == "" {
+			return errors.New("Destination must be an absolute url")
+		}
+
+		var ps *url.URL
+		if proxy != "" {
+			if !strings.Contains(proxy, "http://") && !strings.Contains(proxy, "https://") {
+				proxy = "http://" + proxy // support only http proxy for now
+			}
+
+			ps, err = lib.ParseProxy(proxy)
+			if err != nil {
+				return err
+			}
+
+			if proxyUser != "" {
+				if proxyPass != "" {
+					ps.User = url.UserPassword(proxyUser, proxyPass)
+				} else {
+					ps.User = url.User(proxyUser)
+				}
+			}
+		}
+
+		classifier := lib.NewClassifier()
+		classifier.Retries = retries
+		if err = classifier.Parse(verdicts); err != nil {
+			return
+		}
+
+		disp := lib.NewDispatcher(timeout)
+		disp.Seeder = lib.NewSeeder(seed)
+
+		options := lib.ProbeEmitterOptions{
+			Dest:           ds,
+			Method:         method,
+			Limit:          limit,
+			MaxSize:        maxSize,
+			RequestTimeout: requestTimeout,
+			Classifier:     classifier,
+		}
+
+		if testHeader {
+			for _, proto := range protos {
+				protoOptions := options
+				protoOptions.Proto = proto
+				for _, name := range headerNames {
+					for _, mode := range headerModes {
+						headerStrategy, err := lib.NewStrategy(strategy, headerInc, headerMulti, scheduleFile)
+						if err != nil {
+							return err
+						}
+						content := lib.NewStrategyContent(headerSize, headerStrategy, mode, disp.Seeder.Rand())
+						disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&protoOptions, lib.NewHeaderProbe(name, content), ps))
+					}
+				}
+			}
+		}
+
+		bodyOptions := options
+		if !cmd.Flags().Changed("method") {
+			// GET requests with body are not welcome everywhere
+			bodyOptions.Method = http.MethodPost
+		}
+
+		if testBody {
+			for _, chunkSize := range chunkSizes {
+				if chunkSize == 0 {
+					return errors.New("Chunk size must be positive")
+				}
+			}
+
+			for _, proto := range protos {
+				protoOptions := bodyOptions
+				protoOptions.Proto = proto
+				if bodyFile != "" {
+					if bodyStream || chunked {
+						return errors.New("Body from file can't be streamed")
+					}
+					body, err := lib.NewBodyFromFile(bodyFile)
+					if err != nil {
+						return err
+					}
+					disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&protoOptions, lib.NewBodyProbe(body), ps))
+				} else {
+					for _, mode := range bodyModes {
+						bodyStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+						if err != nil {
+							return err
+						}
+						var probe lib.Probe
+						if bodyStream {
+							probe = lib.NewStreamBodyProbe(lib.NewStreamContent(bodySize, bodyStrategy, mode, disp.Seeder.Rand()), 0)
+						} else {
+							probe = lib.NewBodyProbe(lib.NewStrategyContent(bodySize, bodyStrategy, mode, disp.Seeder.Rand()))
+						}
+						disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&protoOptions, probe, ps))
+
+						// chunked bodies are probed next to the one with content length,
+						// some servers limit only the declared one, http/2 and http/3 have no chunked encoding
+						for _, chunkSize := range chunkSizes {
+							if !chunked || proto != lib.HTTP11 {
+								break
+							}
+							chunkedStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+							if err != nil {
+								return err
+							}
+							content := lib.NewStreamContent(bodySize, chunkedStrategy, mode, disp.Seeder.Rand())
+							disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&protoOptions, lib.NewStreamBodyProbe(content, int(chunkSize)), ps))
+						}
+					}
+				}
+			}
+		}
+
+		if testMultipartParts {
+			countStrategy, err := lib.NewStrategy(strategy, countInc, countMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			probe := lib.NewMultipartPartsProbe(lib.NewCounter(countSize, countStrategy), partValueSize, disp.Seeder.Rand())
+			disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&bodyOptions, probe, ps))
+		}
+
+		if testMultipartFile || testMultipartTotal {
+			for _, mode := range bodyModes {
+				if testMultipartFile {
+					fileStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+					if err != nil {
+						return err
+					}
+					content := lib.NewStreamContent(bodySize, fileStrategy, mode, disp.Seeder.Rand())
+					disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&bodyOptions, lib.NewMultipartFileProbe(content), ps))
+				}
+				if testMultipartTotal {
+					totalStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+					if err != nil {
+						return err
+					}
+					content := lib.NewStreamContent(bodySize, totalStrategy, mode, disp.Seeder.Rand())
+					disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&bodyOptions, lib.NewMultipartTotalProbe(content, multipartFiles), ps))
+				}
+			}
+		}
+
+		if testGrid {
+			headerStrategy, err := lib.NewStrategy(strategy, headerInc, headerMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			bodyStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			header := lib.NewStrategyContent(headerSize, headerStrategy, lib.ModeAlnum, disp.Seeder.Rand())
+			body := lib.NewStrategyContent(bodySize, bodyStrategy, lib.ModeAlnum, disp.Seeder.Rand())
+			gridOptions := &lib.GridOptions{HeaderMax: gridHeaderMax, BodyMax: gridBodyMax, Output: gridOutput, Format: gridFormat}
+			emitter, err := lib.NewGridEmitter(&bodyOptions, gridOptions, header, body, ps)
+			if err != nil {
+				return err
+			}
+			disp.Emitters = append(disp.Emitters, emitter)
+		}
+
+		// probed size of slow probes is sending time in milliseconds
+		if testSlowHeader {
+			slowOptions := options
+			slowOptions.MaxSize = int(slowMax / time.Millisecond)
+			slowStrategy, err := lib.NewStrategy(strategy, uint(slowInc/time.Millisecond), slowMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			counter := lib.NewCounter(uint(slowStart/time.Millisecond), slowStrategy)
+			disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&slowOptions, lib.NewSlowHeaderProbe(slowHeaderLines, counter, disp.Seeder.Rand()), ps))
+		}
+
+		if testSlowBody {
+			slowOptions := bodyOptions
+			slowOptions.MaxSize = int(slowMax / time.Millisecond)
+			for _, size := range slowBodySizes {
+				slowStrategy, err := lib.NewStrategy(strategy, uint(slowInc/time.Millisecond), slowMulti, scheduleFile)
+				if err != nil {
+					return err
+				}
+				// body size is fixed, so it's content never grows
+				body, err := lib.NewStrategyContent(size, nil, lib.ModeAlnum, disp.Seeder.Rand()).SetSize(size)
+				if err != nil {
+					return err
+				}
+				counter := lib.NewCounter(uint(slowStart/time.Millisecond), slowStrategy)
+				disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&slowOptions, lib.NewSlowBodyProbe(body, counter), ps))
+			}
+		}
+
+		keepAliveOptions := &lib.KeepAliveOptions{MaxIdle: keepAliveMaxIdle, MaxRequests: keepAliveMaxRequests}
+		if testKeepAliveIdle {
+			disp.Emitters = append(disp.Emitters, lib.NewIdleTimeoutEmitter(&options, keepAliveOptions, ps))
+		}
+		if testKeepAliveRequests {
+			disp.Emitters = append(disp.Emitters, lib.NewConnRequestsEmitter(&options, keepAliveOptions, ps))
+		}
+
+		if testPipeline {
+			countStrategy, err := lib.NewStrategy(strategy, countInc, countMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, lib.NewPipelineProbe(lib.NewCounter(countSize, countStrategy)), ps))
+		}
+
+		if testConns {
+			connsOptions := &lib.ConnsOptions{MaxConns: maxConns, QueueDelay: queueDelay}
+			disp.Emitters = append(disp.Emitters, lib.NewConnsEmitter(&options, connsOptions, ps))
+		}
+
+		if testWSMessage || testWSFrame {
+			for _, mode := range bodyModes {
+				if testWSMessage {
+					messageStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+					if err != nil {
+						return err
+					}
+					content := lib.NewStrategyContent(bodySize, messageStrategy, mode, disp.Seeder.Rand())
+					disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, lib.NewWebSocketMessageProbe(content, int(wsFrameSize)), ps))
+				}
+				if testWSFrame {
+					frameStrategy, err := lib.NewStrategy(strategy, bodyInc, bodyMulti, scheduleFile)
+					if err != nil {
+						return err
+					}
+					content := lib.NewStrategyContent(bodySize, frameStrategy, mode, disp.Seeder.Rand())
+					disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, lib.NewWebSocketFrameProbe(content), ps))
+				}
+			}
+		}
+
+		if testWSConns {
+			connsOptions := &lib.ConnsOptions{MaxConns: maxConns, QueueDelay: queueDelay}
+			disp.Emitters = append(disp.Emitters, lib.NewWebSocketConnsEmitter(&options, connsOptions, ps))
+		}
+
+		if testH2Limits {
+			// limits are searched over every http/2 protocol to test, or the one matching destination scheme
+			var h2Protos []lib.Protocol
+			for _, proto := range protos {
+				if proto == lib.H2 || proto == lib.H2C {
+					h2Protos = append(h2Protos, proto)
+				}
+			}
+			if len(h2Protos) == 0 && ds.Scheme == "https" {
+				h2Protos = append(h2Protos, lib.H2)
+			} else if len(h2Protos) == 0 {
+				h2Protos = append(h2Protos, lib.H2C)
+			}
+			h2Options := &lib.H2Options{MaxStreams: h2MaxStreams}
+			for _, proto := range h2Protos {
+				protoOptions := options
+				protoOptions.Proto = proto
+				disp.Emitters = append(disp.Emitters, lib.NewH2LimitsEmitter(&protoOptions, h2Options, ps))
+			}
+		}
+
+		if testHeaderCount {
+			countStrategy, err := lib.NewStrategy(strategy, countInc, countMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			probe := lib.NewHeaderCountProbe(lib.NewCounter(countSize, countStrategy), headerValueSize, disp.Seeder.Rand())
+			disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, probe, ps))
+		}
+
+		if testCookieCount {
+			countStrategy, err := lib.NewStrategy(strategy, countInc, countMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			probe := lib.NewCookieCountProbe(lib.NewCounter(countSize, countStrategy), cookieValueSize, disp.Seeder.Rand())
+			disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, probe, ps))
+		}
+
+		if testCookieSize {
+			for _, mode := range cookieModes {
+				cookieStrategy, err := lib.NewStrategy(strategy, headerInc, headerMulti, scheduleFile)
+				if err != nil {
+					return err
+				}
+				content := lib.NewStrategyContent(headerSize, cookieStrategy, mode, disp.Seeder.Rand())
+				disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, lib.NewCookieSizeProbe(cookies, content), ps))
+			}
+		}
+
+		if testQueryCount {
+			countStrategy, err := lib.NewStrategy(strategy, countInc, countMulti, scheduleFile)
+			if err != nil {
+				return err
+			}
+			probe := lib.NewQueryCountProbe(ds, lib.NewCounter(countSize, countStrategy), queryValueSize, disp.Seeder.Rand())
+			disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, probe, ps))
+		}
+
+		if testURI {
+			// compare limits of the target itself with the ones of the proxy in front of it
+			proxies := []*url.URL{ps}
+			if ps != nil {
+				proxies = append(proxies, nil)
+			}
+			for _, proxy := range proxies {
+				for _, part := range []lib.URIPart{lib.URIPath, lib.URIQuery} {
+					uriStrategy, err := lib.NewStrategy(strategy, uriInc, uriMulti, scheduleFile)
+					if err != nil {
+						return err
+					}
+					content := lib.NewStrategyContent(uriSize, uriStrategy, lib.ModeAlnum, disp.Seeder.Rand())
+					disp.Emitters = append(disp.Emitters, lib.NewProbeEmitter(&options, lib.NewURIProbe(ds, part, content), proxy))
+				}
+			}
+		}
+
+		disp.Run()
+
+		return
+	},
+}
+
+const modesUsage = "Comma separated content modes, each one is probed separately [alnum,zeros,binary,utf8,obs-text,json,compressible,incompressible]"
+const headerModesUsage = "Comma separated content modes of header values, each one is probed separately [alnum,utf8,obs-text,json,compressible]"
+
+func init() {
+	RootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().StringP("dest", "d", "", "Requests destination")
+	testCmd.Flags().Uint32P("limit", "l", 0, "Max number of requests per probe, 0 = unlimited")
+	testCmd.Flags().StringP("method", "m", http.MethodGet, "Request method")
+	testCmd.Flags().IntP("timeout", "t", 0, "Maximum test duration(0=endless)")
+	testCmd.Flags().Duration("request-timeout", 10*time.Second, "Single request timeout")
+	testCmd.Flags().String("verdicts", "", "Verdict meanings, e.g. bad-gateway=retry,timeout=accept (meanings: accept, reject, retry)")
+	testCmd.Flags().Int("retries", 2, "How many times request with retry verdict is repeated")
+	testCmd.Flags().Int64("seed", 0, "Seed of generated payloads, use the one printed in the summary to replay a run, 0 = random")
+
+	testCmd.Flags().Bool("header", false, "Search for max header size")
+	testCmd.Flags().Uint("header-size", 1, "Request header initial size (bytes)")
+	testCmd.Flags().Uint("header-inc-rate", 0, "Request header amplification rate (bytes)")
+	testCmd.Flags().Uint("header-multi-rate", 2, "Request header multiplication rate")
+	testCmd.Flags().String("header-modes", "alnum", headerModesUsage)
+	testCmd.Flags().StringSlice("header-names", []string{lib.RequestHeaderName}, "Comma separated header names, each one is probed separately")
+
+	testCmd.Flags().BoolP("body", "b", false, "Search for max body size")
+	testCmd.Flags().Uint("body-size", 1024, "Request body initial size (bytes)")
+	testCmd.Flags().Uint("body-inc-rate", 0, "Request body amplification rate (bytes)")
+	testCmd.Flags().Uint("body-multi-rate", 2, "Request body multiplication rate")
+	testCmd.Flags().String("body-from-file", "", "Read request body content from file")
+	testCmd.Flags().Bool("body-stream", false, "Generate request body while sending it, memory usage doesn't depend on body size")
+	testCmd.Flags().Bool("chunked", false, "Also probe body sent with chunked transfer encoding, once for each chunk size")
+	testCmd.Flags().UintSlice("chunk-sizes", []uint{1, 4096, 1048576}, "Comma separated sizes of chunks chunked body is sent in (bytes)")
+	testCmd.Flags().String("body-modes", "alnum", modesUsage)
+	testCmd.Flags().Bool("uri", false, "Search for max request uri path and query length, both through proxy and directly if proxy is set")
+	testCmd.Flags().Uint("uri-size", 1, "Request uri path or query initial size (bytes)")
+	testCmd.Flags().Uint("uri-inc-rate", 0, "Request uri amplification rate (bytes)")
+	testCmd.Flags().Uint("uri-multi-rate", 2, "Request uri multiplication rate")
+
+	testCmd.Flags().Bool("header-count", false, "Search for max number of header fields")
+	testCmd.Flags().Uint("header-value-size", 8, "Value size of each header in header count probe (bytes)")
+
+	testCmd.Flags().Bool("cookie-count", false, "Search for max number of cookies, fatty server reports silently dropped ones")
+	testCmd.Flags().Uint("cookie-value-size", 8, "Value size of each cookie in cookie count probe (bytes)")
+	testCmd.Flags().Bool("cookie-size", false, "Search for max cookie value size, grown like header with header size flags")
+	testCmd.Flags().Uint("cookies", 1, "Number of cookies grown together in cookie size probe")
+	testCmd.Flags().String("cookie-modes", "alnum", "Comma separated content modes of cookie values, each one is probed separately [alnum,compressible]")
+
+	testCmd.Flags().Bool("multipart-parts", false, "Search for max number of multipart/form-data fields")
+	testCmd.Flags().Uint("part-value-size", 8, "Value size of each field in multipart parts probe (bytes)")
+	testCmd.Flags().Bool("multipart-file", false, "Search for max size of a multipart/form-data file, grown like body with body size and mode flags")
+	testCmd.Flags().Bool("multipart-total", false, "Search for max size of multipart/form-data body made of several files")
+	testCmd.Flags().Uint("multipart-files", 4, "Number of files in multipart total size probe")
+
+	testCmd.Flags().Bool("grid", false, "Send every combination of header and body sizes and draw acceptance map")
+	testCmd.Flags().Int("grid-header-max", 65536, "Max header size in grid (bytes)")
+	testCmd.Flags().Int("grid-body-max", 16777216, "Max body size in grid (bytes)")
+	testCmd.Flags().String("grid-output", "", "Write grid acceptance map to file")
+	testCmd.Flags().String("grid-format", "csv", "Grid acceptance map file format [csv,json]")
+
+	testCmd.Flags().Bool("slow-body", false, "Search for body read timeout, sending body slower and slower")
+	testCmd.Flags().UintSlice("slow-body-sizes", []uint{1024, 65536}, "Comma separated sizes of slowly sent bodies, each one is probed separately (bytes)")
+	testCmd.Flags().Bool("slow-header", false, "Search for header read timeout, sending header lines with longer and longer pauses")
+	testCmd.Flags().Uint("slow-header-lines", 10, "Number of extra header lines pauses are made between in slow header probe")
+	testCmd.Flags().Duration("slow-start", time.Second, "Initial time of sending slow header or body")
+	testCmd.Flags().Duration("slow-inc-rate", 0, "Slow header or body sending time amplification rate")
+	testCmd.Flags().Uint("slow-multi-rate", 2, "Slow header or body sending time multiplication rate")
+	testCmd.Flags().Duration("slow-max", 10*time.Minute, "Stop slowing header or body down beyond this sending time")
+
+	testCmd.Flags().Bool("keepalive-idle", false, "Search for the time idle keep-alive connection is closed after")
+	testCmd.Flags().Bool("keepalive-requests", false, "Search for max number of requests served over a single connection")
+	testCmd.Flags().Duration("max-idle", 10*time.Minute, "Max time of waiting for idle connection to be closed")
+	testCmd.Flags().Int("max-conn-requests", 10000, "Max number of requests sent over a single connection")
+
+	testCmd.Flags().Bool("pipeline", false, "Search for max number of pipelined requests, grown like count probes")
+	testCmd.Flags().Bool("conns", false, "Search for max number of concurrent connections, each one with a request in flight")
+	testCmd.Flags().Int("max-conns", 10000, "Stop opening connections after this number of them")
+	testCmd.Flags().Duration("queue-delay", 500*time.Millisecond, "Request is considered queued if it's response comes this much later than the fastest one")
+
+	testCmd.Flags().StringSlice("protocols", []string{string(lib.HTTP11)}, "Comma separated protocols header and body probes are run over, each one separately [http/1.1,h2,h2c,h3]")
+	testCmd.Flags().Bool("h2-limits", false, "Search for max concurrent http/2 streams and max frame size, over h2 or h2c protocols to test")
+	testCmd.Flags().Int("h2-max-streams", 1000, "Stop opening http/2 streams after this number of them")
+
+	testCmd.Flags().Bool("ws-message", false, "Search for max websocket message size, sent in frames of fixed size and grown like body with body size and mode flags")
+	testCmd.Flags().Bool("ws-frame", false, "Search for max websocket frame size, the whole message is sent in a single frame")
+	testCmd.Flags().Uint("ws-frame-size", 4096, "Size of frames websocket message is split into in message size probe (bytes)")
+	testCmd.Flags().Bool("ws-conns", false, "Search for max number of concurrent websocket connections, with max conns and queue delay flags")
+
+	testCmd.Flags().Bool("query-count", false, "Search for max number of query parameters, fatty server reports silently dropped ones")
+	testCmd.Flags().Uint("query-value-size", 8, "Value size of each parameter in query count probe (bytes)")
+
+	testCmd.Flags().Uint("count-size", 1, "Initial number of items in count probes")
+	testCmd.Flags().Uint("count-inc-rate", 0, "Number of items amplification rate in count probes")
+	testCmd.Flags().Uint("count-multi-rate", 2, "Number of items multiplication rate in count probes")
+
+	testCmd.Flags().String("strategy", "", "Probed value growing strategy [linear,expo,fibonacci,schedule], by default linear if inc rate is set, expo otherwise")
+	testCmd.Flags().String("schedule-file", "", "File with explicit list of sizes for schedule strategy")
+	testCmd.Flags().Int("max-size", 0, "Stop growing probed value beyond this size (bytes), 0 = unlimited")
+
+	testCmd.Flags().StringP("proxy", "p", "", "Proxy server url. Can contain basic proxy authentication.")
+	testCmd.Flags().String("proxy-user", "", "Proxy user login")
+	testCmd.Flags().String("proxy-pass", "", "Proxy user password")
+}
+
+// configString returns value from config file if it's set there
+func configString(key, value string) string {
+	if viper.IsSet(key) {
+		return viper.GetString(key)
+	}
+	return value
+}
+
+// configUint returns value from config file if it's set there
+func configUint(key string, value uint) uint {
+	if viper.IsSet(key) {
+		return uint(viper.GetInt(key))
+	}
+	return value
+}
@@ -0,0 +1,226 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+// Verdict explains the outcome of a single request
+type Verdict int
+
+const (
+	VerdictOK Verdict = iota
+	VerdictBadRequest
+	VerdictPayloadTooLarge
+	VerdictURITooLong
+	VerdictHeaderTooLarge
+	VerdictBadGateway
+	VerdictClientError
+	VerdictServerError
+	VerdictReset
+	VerdictTimeout
+	VerdictTruncated
+	VerdictError
+	// response is successful, but server has silently dropped some of request parts
+	VerdictDropped
+	// connection was refused
+	VerdictRefused
+	// response came much later than usual, request was waiting for a free slot
+	VerdictQueued
+	// pipelined responses came in different order than requests
+	VerdictReordered
+)
+
+var verdictNames = map[Verdict]string{
+	VerdictOK:              "ok",
+	VerdictBadRequest:      "bad-request",
+	VerdictPayloadTooLarge: "payload-too-large",
+	VerdictURITooLong:      "uri-too-long",
+	VerdictHeaderTooLarge:  "header-too-large",
+	VerdictBadGateway:      "bad-gateway",
+	VerdictClientError:     "client-error",
+	VerdictServerError:     "server-error",
+	VerdictReset:           "reset",
+	VerdictTimeout:         "timeout",
+	VerdictTruncated:       "truncated",
+	VerdictError:           "error",
+	VerdictDropped:         "dropped",
+	VerdictRefused:         "refused",
+	VerdictQueued:          "queued",
+	VerdictReordered:       "reordered",
+}
+
+func (v Verdict) String() string {
+	if name, ok := verdictNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("verdict(%d)", int(v))
+}
+
+func ParseVerdict(s string) (Verdict, error) {
+	for v, name := range verdictNames {
+		if name == s {
+			return v, nil
+		}
+	}
+	return VerdictError, errors.New(fmt.Sprintf("Unknown verdict: %s", s))
+}
+
+// Meaning tells probes how to treat a verdict
+type Meaning int
+
+const (
+	MeaningAccept Meaning = iota
+	MeaningReject
+	// request is repeated, if verdict persists it's counted as rejected
+	MeaningRetry
+)
+
+var meaningNames = map[Meaning]string{
+	MeaningAccept: "accept",
+	MeaningReject: "reject",
+	MeaningRetry:  "retry",
+}
+
+func (m Meaning) String() string {
+	return meaningNames[m]
+}
+
+func ParseMeaning(s string) (Meaning, error) {
+	for m, name := range meaningNames {
+		if name == s {
+			return m, nil
+		}
+	}
+	return MeaningReject, errors.New(fmt.Sprintf("Unknown verdict meaning: %s", s))
+}
+
+// ClassifiedError is a connection error with the verdict it was classified as
+type ClassifiedError struct {
+	Verdict Verdict
+	Err     error
+}
+
+func (e *ClassifiedError) Error() string {
+	return fmt.Sprintf("Error: %s (%s)", e.Err, e.Verdict)
+}
+
+// Classifier maps responses to verdicts and verdicts to their meaning
+type Classifier struct {
+	meanings map[Verdict]Meaning
+	// How many times request with MeaningRetry verdict is repeated
+	Retries int
+}
+
+// NewClassifier returns classifier which accepts only successful responses,
+// including ones which silently dropped some request parts, probes report them separately
+func NewClassifier() *Classifier {
+	meanings := make(map[Verdict]Meaning, len(verdictNames))
+	for v := range verdictNames {
+		meanings[v] = MeaningReject
+	}
+	meanings[VerdictOK] = MeaningAccept
+	meanings[VerdictDropped] = MeaningAccept
+	return &Classifier{meanings: meanings, Retries: 2}
+}
+
+func (c *Classifier) Set(v Verdict, m Meaning) {
+	c.meanings[v] = m
+}
+
+// Parse reads comma separated verdict=meaning pairs, e.g. "bad-gateway=retry,timeout=accept"
+func (c *Classifier) Parse(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return errors.New(fmt.Sprintf("Verdict meaning must look like verdict=meaning, got: %s", pair))
+		}
+		v, err := ParseVerdict(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return err
+		}
+		m, err := ParseMeaning(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return err
+		}
+		c.Set(v, m)
+	}
+	return nil
+}
+
+func (c *Classifier) Meaning(v Verdict) Meaning {
+	if m, ok := c.meanings[v]; ok {
+		return m
+	}
+	return MeaningReject
+}
+
+// Classify returns verdict for the response or the error returned instead of it
+func (c *Classifier) Classify(resp *fasthttp.Response, err error) Verdict {
+	if err != nil {
+		return classifyError(err)
+	}
+	return classifyCode(resp.StatusCode())
+}
+
+func classifyCode(code int) Verdict {
+	switch {
+	case code == fasthttp.StatusBadRequest:
+		return VerdictBadRequest
+	case code == fasthttp.StatusRequestEntityTooLarge:
+		return VerdictPayloadTooLarge
+	case code == fasthttp.StatusRequestURITooLong:
+		return VerdictURITooLong
+	case code == fasthttp.StatusRequestHeaderFieldsTooLarge:
+		return VerdictHeaderTooLarge
+	case code == fasthttp.StatusBadGateway:
+		return VerdictBadGateway
+	case code >= 500:
+		return VerdictServerError
+	case code >= 400:
+		return VerdictClientError
+	}
+	return VerdictOK
+}
+
+func classifyError(err error) Verdict {
+	var netErr net.Error
+	var chunkErr fasthttp.ErrBrokenChunk
+	var streamErr http2.StreamError
+	var goAwayErr http2.GoAwayError
+	var h3Err *http3.Error
+	var quicStreamErr *quic.StreamError
+	var quicAppErr *quic.ApplicationError
+	var quicTransportErr *quic.TransportError
+	var closeErr *websocket.CloseError
+	switch {
+	case errors.As(err, &closeErr) && closeErr.Code == websocket.CloseMessageTooBig:
+		return VerdictPayloadTooLarge
+	case errors.Is(err, fasthttp.ErrTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return VerdictTimeout
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &chunkErr):
+		return VerdictTruncated
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return VerdictRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, fasthttp.ErrConnectionClosed), errors.Is(err, io.EOF),
+		errors.As(err, &streamErr), errors.As(err, &goAwayErr),
+		errors.As(err, &h3Err), errors.As(err, &quicStreamErr), errors.As(err, &quicAppErr), errors.As(err, &quicTransportErr),
+		errors.As(err, &closeErr):
+		return VerdictReset
+	}
+	return VerdictError
+}
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+func TestClassifyCode(t *testing.T) {
+	tests := []struct {
+		code int
+		want Verdict
+	}{
+		{200, VerdictOK},
+		{204, VerdictOK},
+		{301, VerdictOK},
+		{400, VerdictBadRequest},
+		{403, VerdictClientError},
+		{407, VerdictClientError},
+		{413, VerdictPayloadTooLarge},
+		{414, VerdictURITooLong},
+		{431, VerdictHeaderTooLarge},
+		{500, VerdictServerError},
+		{502, VerdictBadGateway},
+		{503, VerdictServerError},
+		{504, VerdictServerError},
+	}
+	for _, tt := range tests {
+		if got := classifyCode(tt.code); got != tt.want {
+			t.Errorf("classifyCode(%d) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+// dialError wraps errno the way net package returns it
+func dialError(errno syscall.Errno) error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Verdict
+	}{
+		{fasthttp.ErrTimeout, VerdictTimeout},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, VerdictTimeout},
+		{io.ErrUnexpectedEOF, VerdictTruncated},
+		{fasthttp.ErrBrokenChunk{}, VerdictTruncated},
+		{dialError(syscall.ECONNREFUSED), VerdictRefused},
+		{dialError(syscall.ECONNRESET), VerdictReset},
+		{dialError(syscall.EPIPE), VerdictReset},
+		{fasthttp.ErrConnectionClosed, VerdictReset},
+		{io.EOF, VerdictReset},
+		{fmt.Errorf("reading response: %w", io.EOF), VerdictReset},
+		{http2.StreamError{StreamID: 1, Code: http2.ErrCodeRefusedStream}, VerdictReset},
+		{http2.GoAwayError{ErrCode: http2.ErrCodeEnhanceYourCalm}, VerdictReset},
+		{&quic.StreamError{StreamID: 0, ErrorCode: 0x10c}, VerdictReset},
+		{&quic.ApplicationError{ErrorCode: 0x101}, VerdictReset},
+		{&websocket.CloseError{Code: websocket.CloseMessageTooBig}, VerdictPayloadTooLarge},
+		{&websocket.CloseError{Code: websocket.CloseGoingAway}, VerdictReset},
+		{errors.New("unknown"), VerdictError},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	c := NewClassifier()
+
+	resp.SetStatusCode(fasthttp.StatusRequestEntityTooLarge)
+	if got := c.Classify(resp, nil); got != VerdictPayloadTooLarge {
+		t.Errorf("Classify(413, nil) = %s, want %s", got, VerdictPayloadTooLarge)
+	}
+	// error wins over whatever status code is left in response
+	if got := c.Classify(resp, io.EOF); got != VerdictReset {
+		t.Errorf("Classify(413, EOF) = %s, want %s", got, VerdictReset)
+	}
+}
+
+func TestClassifierParse(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    map[Verdict]Meaning
+		wantErr bool
+	}{
+		{s: "", want: map[Verdict]Meaning{VerdictOK: MeaningAccept, VerdictDropped: MeaningAccept, VerdictTimeout: MeaningReject}},
+		{s: "bad-gateway=retry, timeout=accept", want: map[Verdict]Meaning{VerdictBadGateway: MeaningRetry, VerdictTimeout: MeaningAccept, VerdictReset: MeaningReject}},
+		{s: "ok=reject,", want: map[Verdict]Meaning{VerdictOK: MeaningReject}},
+		{s: "timeout", wantErr: true},
+		{s: "slow=accept", wantErr: true},
+		{s: "timeout=ignore", wantErr: true},
+	}
+	for _, tt := range tests {
+		c := NewClassifier()
+		err := c.Parse(tt.s)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q): expected error", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %s", tt.s, err)
+			continue
+		}
+		for v, m := range tt.want {
+			if got := c.Meaning(v); got != m {
+				t.Errorf("Parse(%q): meaning of %s = %s, want %s", tt.s, v, got, m)
+			}
+		}
+	}
+}
+
+func TestParseVerdict(t *testing.T) {
+	for v, name := range verdictNames {
+		got, err := ParseVerdict(v.String())
+		if err != nil || got != v {
+			t.Errorf("ParseVerdict(%q) = %s, %v, want %s", name, got, err, v)
+		}
+	}
+	if _, err := ParseVerdict("fine"); err == nil {
+		t.Error("ParseVerdict(\"fine\"): expected error")
+	}
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ConnsEmitter opens connections one by one and keeps every of them busy,
+// until the server refuses, resets or queues the next one
+type ConnsEmitter struct {
+	client     *RawClient
+	proxy      *url.URL
+	classifier *Classifier
+	name       string
+	// open opens a new connection and makes the first request over it, connection is nil
+	// if it's not opened. Verdict is error without status code only for local failures.
+	open func(log chan EmitterEvent) (io.Closer, Verdict, int, time.Duration)
+
+	options      *ProbeEmitterOptions
+	connsOptions *ConnsOptions
+}
+
+type ConnsOptions struct {
+	// Stop opening connections after this number of them
+	MaxConns int
+	// Request is queued if it's response comes this much later than the fastest one
+	QueueDelay time.Duration
+}
+
+// NewConnsEmitter leaves a request in flight on every connection
+func NewConnsEmitter(options *ProbeEmitterOptions, connsOptions *ConnsOptions, proxy *url.URL) Emitter {
+	emitter := newConnsEmitter("concurrent connections", options, connsOptions, proxy)
+	emitter.client = NewRawClient(options.Dest, proxy)
+	emitter.open = emitter.openRequest
+	return emitter
+}
+
+func newConnsEmitter(name string, options *ProbeEmitterOptions, connsOptions *ConnsOptions, proxy *url.URL) *ConnsEmitter {
+	emitter := &ConnsEmitter{}
+	emitter.name = name
+	emitter.options = options
+	emitter.connsOptions = connsOptions
+	emitter.proxy = proxy
+	emitter.classifier = options.classifier()
+	return emitter
+}
+
+func (e *ConnsEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
+
+	result := newProbeResult(e.name, e.proxy)
+	result.Unit = "connections"
+
+	var conns []io.Closer
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		log <- result
+		done <- struct{}{}
+	}()
+
+	var fastest time.Duration
+	for n := 1; n <= e.connsOptions.MaxConns; n++ {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			return
+		}
+
+		conn, verdict, code, latency := e.open(log)
+		result.Requests++
+		if conn != nil {
+			conns = append(conns, conn)
+		}
+		if verdict == VerdictError && conn == nil && code == 0 {
+			// it's our own limit, not the server's one
+			result.Interrupted = true
+			return
+		}
+		if e.classifier.Meaning(verdict) == MeaningAccept && (fastest == 0 || latency < fastest) {
+			fastest = latency
+		}
+		if e.classifier.Meaning(verdict) == MeaningAccept && latency-fastest > e.connsOptions.QueueDelay {
+			verdict = VerdictQueued
+			result.Details = append(result.Details, fmt.Sprintf("response on connection %d came in %s, the fastest one in %s", n, latency, fastest))
+		}
+
+		if verdict == VerdictTimeout && conn != nil {
+			result.Details = append(result.Details, fmt.Sprintf("connection %d was opened, but it's request timed out, it's likely queued", n))
+		} else if verdict == VerdictTimeout {
+			result.Details = append(result.Details, fmt.Sprintf("opening of connection %d timed out, it's likely queued", n))
+		}
+		if e.classifier.Meaning(verdict) != MeaningAccept {
+			result.Rejected, result.Verdict, result.Code = n, verdict, code
+			result.Confirmed = true
+			return
+		}
+		result.Accepted = n
+	}
+}
+
+// localLimit reports error of opening connection if it's caused by a local limit
+func localLimit(err error, log chan EmitterEvent) bool {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.EADDRNOTAVAIL) {
+		log <- errors.New(fmt.Sprintf("Error: local limit of connections is reached: %s", err))
+		return true
+	}
+	return false
+}
+
+// openRequest dials a new connection, sends a request over it and leaves another one in flight
+func (e *ConnsEmitter) openRequest(log chan EmitterEvent) (io.Closer, Verdict, int, time.Duration) {
+
+	start := time.Now()
+	conn, err := e.client.Dial(e.options.RequestTimeout)
+	if err != nil {
+		if localLimit(err, log) {
+			return nil, VerdictError, 0, 0
+		}
+		verdict := classifyError(err)
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return nil, verdict, 0, 0
+	}
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(e.options.Dest.String())
+	req.Header.SetMethod(e.options.Method)
+	setProxyAuthorization(req, e.proxy)
+
+	err = conn.DoTimeout(req, resp, e.options.RequestTimeout)
+	latency := time.Since(start)
+	verdict := e.classifier.Classify(resp, err)
+	if err != nil {
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return conn, verdict, 0, latency
+	}
+	log <- LoadEmitterEvent{
+		Code:          resp.StatusCode(),
+		RequestTime:   latency,
+		RequestLength: resp.Header.ContentLength(),
+		Verdict:       verdict,
+	}
+
+	// request with a body which is never sent keeps the server busy with connection
+	req.Reset()
+	req.SetRequestURI(e.options.Dest.String())
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentLength(1)
+	setProxyAuthorization(req, e.proxy)
+	if err = conn.WriteHeader(req); err != nil {
+		verdict = classifyError(err)
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+	}
+	return conn, verdict, resp.StatusCode(), latency
+}
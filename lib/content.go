@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"errors"
 	"sync"
 	"math/rand"
 	"io/ioutil"
@@ -8,51 +9,53 @@ import (
 
 var letterBytes = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ01234567890")
 
-func genRandomBytes(n uint) []byte {
-	b := make([]byte, int(n))
-	for i := range b {
-		b[i] = letterBytes[rand.Intn(len(letterBytes))]
-	}
-	return b
-}
-
 type GrowableContent interface {
 	// tries to grow payload of content according to content settings
 	Grow() ([]byte, error)
+	// changes payload to exactly n bytes
+	SetSize(n uint) ([]byte, error)
+	// tries to step payload back according to content settings
+	Shrink() ([]byte, error)
 }
 
 type Content struct {
-	size          uint
-	incValue      uint
-	multiplyValue uint
+	size     uint
+	strategy Strategy
 
 	payload       []byte
+	mode          Mode
+	key           uint64
 	mutex         *sync.Mutex
 }
 
+// NewContent creates content which grows by i bytes or, if i is zero, m times
 func NewContent(s, i, m uint) *Content {
-	return &Content{s, i, m, nil, &sync.Mutex{}}
+	rnd := NewSeeder(0).Rand()
+	if i > 0 {
+		return NewStrategyContent(s, &LinearStrategy{Step: i}, ModeAlnum, rnd)
+	}
+	return NewStrategyContent(s, &ExponentialStrategy{Factor: m}, ModeAlnum, rnd)
 }
 
-// Grow is the function that allows content's payload grow according to it's settings.
+// NewStrategyContent creates content of initial size s which grows according to strategy,
+// payload is generated in given mode from the key drawn from rnd
+func NewStrategyContent(s uint, strategy Strategy, mode Mode, rnd *rand.Rand) *Content {
+	return &Content{s, strategy, nil, mode, rnd.Uint64(), &sync.Mutex{}}
+}
+
+// Grow is the function that allows content's payload grow according to it's strategy.
 func (h *Content) Grow() ([]byte, error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 	if h.payload == nil {
 		// first request
-		h.payload = genRandomBytes(h.size)
-		return h.payload, nil
-	}
-	// increment setting has higher priority
-	if h.incValue > 0 {
-		h.payload = append(h.payload, genRandomBytes(h.incValue)...)
-		h.size += h.incValue
+		h.payload = []byte{}
+		h.setSize(h.size)
 		return h.payload, nil
 	}
 
-	if h.multiplyValue > 1 {
-		h.payload = append(h.payload, genRandomBytes(h.size * h.multiplyValue - uint(len(h.payload)))...)
-		h.size *= h.multiplyValue
+	if next := h.strategy.Next(h.size); next > h.size {
+		h.setSize(next)
 		return h.payload, nil
 	}
 	// payload doesn't grow
@@ -60,11 +63,61 @@ func (h *Content) Grow() ([]byte, error) {
 	return h.payload, nil
 }
 
-var _ GrowableContent = (*Content)(nil)
+// SetSize truncates payload or extends it with generated bytes up to n bytes.
+func (h *Content) SetSize(n uint) ([]byte, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.setSize(n)
+	return h.payload, nil
+}
+
+// Shrink moves payload one strategy step back.
+func (h *Content) Shrink() ([]byte, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if prev := h.strategy.Prev(h.size); prev < h.size {
+		h.setSize(prev)
+	}
+	return h.payload, nil
+}
+
+func (h *Content) setSize(n uint) {
+	gen := h.mode.generator()
+	size := uint(len(h.payload))
+	// bytes changed by the previous seal are generated once again
+	from := size - minUint(size, gen.tailSize)
+	if n <= size {
+		h.payload = h.payload[:n]
+	} else {
+		h.payload = append(h.payload, make([]byte, n-size)...)
+	}
+	if from < n {
+		gen.fill(h.key, h.payload[from:], from)
+	}
+	gen.seal(h.payload[n-minUint(n, gen.tailSize):], n)
+	h.size = n
+}
+
+func (h *Content) Mode() Mode {
+	return h.mode
+}
+
+func minUint(a, b uint) uint {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var (
+	_ GrowableContent = (*Content)(nil)
+	_ ModalContent    = (*Content)(nil)
+)
 
 const RequestHeaderName string = "Sample-Header"
 
 type BodyFromFile struct {
+	source  []byte
 	payload []byte
 }
 
@@ -73,11 +126,29 @@ func NewBodyFromFile(f string) (*BodyFromFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &BodyFromFile{payload: payload}, nil
+	return &BodyFromFile{source: payload, payload: payload}, nil
 }
 
 func (b *BodyFromFile) Grow() ([]byte, error) {
 	return b.payload, nil
 }
 
+// SetSize truncates file content or repeats it until payload has n bytes.
+func (b *BodyFromFile) SetSize(n uint) ([]byte, error) {
+	if len(b.source) == 0 && n > 0 {
+		return b.payload, errors.New("Can't resize body from empty file")
+	}
+	payload := make([]byte, n)
+	for i := 0; i < len(payload); i += len(b.source) {
+		copy(payload[i:], b.source)
+	}
+	b.payload = payload
+	return b.payload, nil
+}
+
+// Shrink halves the payload.
+func (b *BodyFromFile) Shrink() ([]byte, error) {
+	return b.SetSize(uint(len(b.payload) / 2))
+}
+
 var _ GrowableContent = (*BodyFromFile)(nil)
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/valyala/fasthttp"
+)
+
+// prefix of cookie names set by cookie probes
+const CookiePrefix = "fatty"
+
+// CookieCountProbe grows number of small cookies in Cookie header
+type CookieCountProbe struct {
+	counter *Counter
+	items   *items
+	// cookie header size by cookies count
+	headerBytes map[int]int
+}
+
+func NewCookieCountProbe(counter *Counter, valueSize uint, rnd *rand.Rand) *CookieCountProbe {
+	return &CookieCountProbe{
+		counter:     counter,
+		items:       &items{prefix: CookiePrefix, valueSize: valueSize, mode: ModeAlnum, key: rnd.Uint64()},
+		headerBytes: make(map[int]int),
+	}
+}
+
+func (p *CookieCountProbe) Name() string {
+	return "cookie count"
+}
+
+func (p *CookieCountProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *CookieCountProbe) Prepare(req *fasthttp.Request, size int) error {
+	// setting cookies one by one takes quadratic time
+	value := cookieHeader(p.items.get(size))
+	req.Header.SetBytesV(fasthttp.HeaderCookie, value)
+	p.headerBytes[size] = len(value)
+	return nil
+}
+
+// Check compares number of sent cookies with the one received by fatty server
+func (p *CookieCountProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	echo := parseEcho(resp)
+	if echo == nil {
+		return VerdictOK
+	}
+	names, values := p.items.get(size)
+	for i := range names {
+		if length, ok := echo.Cookies[names[i]]; !ok || length != len(values[i]) {
+			return VerdictDropped
+		}
+	}
+	return VerdictOK
+}
+
+func (p *CookieCountProbe) Report(result *ProbeResult) {
+	result.Unit = "cookies"
+	if bytes, ok := p.headerBytes[result.Accepted]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("cookie header at max count: %d bytes", bytes))
+	}
+}
+
+var (
+	_ Probe          = (*CookieCountProbe)(nil)
+	_ CheckingProbe  = (*CookieCountProbe)(nil)
+	_ ReportingProbe = (*CookieCountProbe)(nil)
+)
+
+// CookieSizeProbe grows value size of each of a fixed number of cookies
+type CookieSizeProbe struct {
+	count   int
+	content GrowableContent
+	names   []string
+	// cookie header size by cookie value size
+	headerBytes map[int]int
+}
+
+func NewCookieSizeProbe(count uint, content GrowableContent) *CookieSizeProbe {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", CookiePrefix, i)
+	}
+	return &CookieSizeProbe{count: int(count), content: content, names: names, headerBytes: make(map[int]int)}
+}
+
+func (p *CookieSizeProbe) Name() string {
+	return fmt.Sprintf("cookie size (%d cookies)", p.count)
+}
+
+func (p *CookieSizeProbe) Grow() (int, error) {
+	payload, err := p.content.Grow()
+	return len(payload), err
+}
+
+func (p *CookieSizeProbe) Prepare(req *fasthttp.Request, size int) error {
+	payload, err := p.content.SetSize(uint(size))
+	if err != nil {
+		return err
+	}
+	values := make([][]byte, len(p.names))
+	for i, name := range p.names {
+		req.Header.SetCookieBytesKV([]byte(name), payload)
+		values[i] = payload
+	}
+	p.headerBytes[size] = len(cookieHeader(p.names, values))
+	return nil
+}
+
+// Check makes sure fatty server received every cookie in full
+func (p *CookieSizeProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	echo := parseEcho(resp)
+	if echo == nil {
+		return VerdictOK
+	}
+	for _, name := range p.names {
+		if length, ok := echo.Cookies[name]; !ok || length != size {
+			return VerdictDropped
+		}
+	}
+	return VerdictOK
+}
+
+func (p *CookieSizeProbe) Report(result *ProbeResult) {
+	if bytes, ok := p.headerBytes[result.Accepted]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("cookie header at max size: %d bytes", bytes))
+	}
+}
+
+func (p *CookieSizeProbe) Mode() Mode {
+	if m, ok := p.content.(ModalContent); ok {
+		return m.Mode()
+	}
+	return ""
+}
+
+var (
+	_ Probe          = (*CookieSizeProbe)(nil)
+	_ CheckingProbe  = (*CookieSizeProbe)(nil)
+	_ ReportingProbe = (*CookieSizeProbe)(nil)
+)
+
+// cookieHeader returns Cookie header value: "name=value; name=value"
+func cookieHeader(names []string, values [][]byte) []byte {
+	var b []byte
+	for i := range names {
+		if i > 0 {
+			b = append(b, "; "...)
+		}
+		b = append(b, names[i]...)
+		b = append(b, '=')
+		b = append(b, values[i]...)
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Counter grows a number of request items according to strategy
+type Counter struct {
+	count    uint
+	strategy Strategy
+	started  bool
+}
+
+func NewCounter(start uint, strategy Strategy) *Counter {
+	return &Counter{count: start, strategy: strategy}
+}
+
+func (c *Counter) Grow() int {
+	if !c.started {
+		c.started = true
+	} else if next := c.strategy.Next(c.count); next > c.count {
+		c.count = next
+	}
+	return int(c.count)
+}
+
+// items keeps generated names and values, so item i is the same in every request
+type items struct {
+	prefix    string
+	valueSize uint
+	mode      Mode
+	key       uint64
+
+	names  []string
+	values [][]byte
+}
+
+func (it *items) get(n int) ([]string, [][]byte) {
+	gen := it.mode.generator()
+	for i := len(it.names); i < n; i++ {
+		value := make([]byte, it.valueSize)
+		gen.fill(it.key, value, uint(i)*it.valueSize)
+		gen.seal(value[it.valueSize-minUint(it.valueSize, gen.tailSize):], it.valueSize)
+		it.names = append(it.names, fmt.Sprintf("%s%d", it.prefix, i))
+		it.values = append(it.values, value)
+	}
+	return it.names[:n], it.values[:n]
+}
+
+// HeaderCountProbe grows number of distinct small header fields
+type HeaderCountProbe struct {
+	counter *Counter
+	items   *items
+	// header block size by headers count
+	headerBytes map[int]int
+}
+
+func NewHeaderCountProbe(counter *Counter, valueSize uint, rnd *rand.Rand) *HeaderCountProbe {
+	return &HeaderCountProbe{
+		counter:     counter,
+		items:       &items{prefix: "X-Fatty-", valueSize: valueSize, mode: ModeAlnum, key: rnd.Uint64()},
+		headerBytes: make(map[int]int),
+	}
+}
+
+func (p *HeaderCountProbe) Name() string {
+	return "header count"
+}
+
+func (p *HeaderCountProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *HeaderCountProbe) Prepare(req *fasthttp.Request, size int) error {
+	names, values := p.items.get(size)
+	for i := range names {
+		req.Header.SetBytesV(names[i], values[i])
+	}
+	p.headerBytes[size] = len(req.Header.Header())
+	return nil
+}
+
+func (p *HeaderCountProbe) Report(result *ProbeResult) {
+	result.Unit = "headers"
+	if bytes, ok := p.headerBytes[result.Accepted]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("header block at max count: %d bytes", bytes))
+	}
+}
+
+var (
+	_ Probe          = (*HeaderCountProbe)(nil)
+	_ ReportingProbe = (*HeaderCountProbe)(nil)
+)
@@ -13,11 +13,16 @@ import (
 type Dispatcher struct {
 	stats *LoadRunStats
 	Proxy *url.URL
+	// Source of run's random data, it's seed is printed in the summary
+	Seeder *Seeder
 
 	Emitters []Emitter
 
 	log chan EmitterEvent
 
+	results []ProbeResult
+	grids   []GridResult
+
 	deadLine *time.Timer
 
 	stop, done chan struct{}
@@ -28,6 +33,7 @@ func NewDispatcher(timeout int) *Dispatcher {
 	stats := &LoadRunStats{
 		counter: atomic.NewInt32(0),
 		statusCodes: make(map[int]int),
+		verdicts: make(map[Verdict]int),
 		startTime: time.Now(),
 	}
 
@@ -57,27 +63,7 @@ func (d *Dispatcher) Run() {
 	for nthreads > 0 {
 		select {
 		case event := <-d.log:
-			switch msg := event.(type) {
-			case LoadEmitterEvent:
-				if _, ok := d.stats.statusCodes[msg.Code]; ok {
-					d.stats.statusCodes[msg.Code]++
-				} else {
-					d.stats.statusCodes[msg.Code] = 1
-				}
-				d.stats.totalTime += msg.RequestTime
-				if d.stats.minRequestTime > msg.RequestTime || d.stats.minRequestTime == 0 {
-					d.stats.minRequestTime = msg.RequestTime
-				}
-				if d.stats.maxRequestTime < msg.RequestTime {
-					d.stats.maxRequestTime = msg.RequestTime
-				}
-				d.stats.totalBytes += msg.RequestLength
-				d.stats.counter.Add(1)
-			case error:
-				d.stats.errorCounter++
-			default:
-				fmt.Printf("Unknown event: %#v", msg)
-			}
+			d.handle(event)
 		case <-d.done:
 			nthreads -= 1
 		case <-interrupt:
@@ -90,7 +76,56 @@ func (d *Dispatcher) Run() {
 		}
 	}
 
+	// emitters may report right before they are done
+	for len(d.log) > 0 {
+		d.handle(<-d.log)
+	}
+
 	d.stats.Print()
+	if d.Seeder != nil {
+		fmt.Printf("Seed: %d\n", d.Seeder.Seed())
+	}
+	for _, result := range d.results {
+		result.Print()
+	}
+	PrintResultsTable(d.results)
+	PrintModeMatrix(d.results)
+	PrintProtocolMatrix(d.results)
+	for _, grid := range d.grids {
+		grid.Print()
+	}
+}
+
+func (d *Dispatcher) handle(event EmitterEvent) {
+	switch msg := event.(type) {
+	case LoadEmitterEvent:
+		if _, ok := d.stats.statusCodes[msg.Code]; ok {
+			d.stats.statusCodes[msg.Code]++
+		} else {
+			d.stats.statusCodes[msg.Code] = 1
+		}
+		d.stats.totalTime += msg.RequestTime
+		if d.stats.minRequestTime > msg.RequestTime || d.stats.minRequestTime == 0 {
+			d.stats.minRequestTime = msg.RequestTime
+		}
+		if d.stats.maxRequestTime < msg.RequestTime {
+			d.stats.maxRequestTime = msg.RequestTime
+		}
+		d.stats.totalBytes += msg.RequestLength
+		d.stats.verdicts[msg.Verdict]++
+		d.stats.counter.Add(1)
+	case ProbeResult:
+		d.results = append(d.results, msg)
+	case GridResult:
+		d.grids = append(d.grids, msg)
+	case *ClassifiedError:
+		d.stats.verdicts[msg.Verdict]++
+		d.stats.errorCounter++
+	case error:
+		d.stats.errorCounter++
+	default:
+		fmt.Printf("Unknown event: %#v", msg)
+	}
 }
 
 type RequestCounter struct {
@@ -124,6 +159,7 @@ type LoadRunStats struct {
 	counter *atomic.Int32
 	errorCounter int
 	statusCodes map[int]int
+	verdicts map[Verdict]int
 	totalTime time.Duration
 	totalBytes int
 
@@ -140,6 +176,10 @@ func (rs *LoadRunStats) Print() {
 		fmt.Printf("%d: %d\n", code, count)
 	}
 	fmt.Printf("Connection errors: %d\n", rs.errorCounter)
+	fmt.Println("Verdict information:")
+	for verdict, count := range rs.verdicts {
+		fmt.Printf("%s: %d\n", verdict, count)
+	}
 	// count cumulative values
 	bandwidth := float64(rs.totalBytes) / rs.totalTime.Seconds()
 	fmt.Printf("Max request time: %s\n", rs.maxRequestTime)
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"encoding/json"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Echo is what fatty server reports back about the request it has received,
+// probes compare it with what they've sent to detect silently dropped request parts
+type Echo struct {
+	// Value length by cookie name
+	Cookies map[string]int `json:"cookies"`
+	// Value length by query parameter name
+	Query map[string]int `json:"query"`
+	// Value or file length by multipart/form-data part name
+	Parts map[string]int `json:"parts"`
+	// Length of body read by the server
+	Body int `json:"body"`
+}
+
+// parseEcho returns nil if response doesn't come from fatty server
+func parseEcho(resp *fasthttp.Response) *Echo {
+	if string(resp.Header.ContentType()) != EchoContentType {
+		return nil
+	}
+	var echo Echo
+	if err := json.Unmarshal(resp.Body(), &echo); err != nil {
+		return nil
+	}
+	return &echo
+}
+
+const EchoContentType = "application/x-fatty-echo+json"
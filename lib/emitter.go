@@ -5,8 +5,6 @@ import (
 	"github.com/valyala/fasthttp"
 	"encoding/base64"
 	"net/http"
-	"errors"
-	"fmt"
 	"time"
 )
 
@@ -32,6 +30,7 @@ type BaseEmitter struct {
 type LoadEmitter struct {
 	client *fasthttp.HostClient
 	proxy *url.URL
+	classifier *Classifier
 
 	options *LoadEmitterOptions
 }
@@ -46,6 +45,8 @@ type LoadEmitterEvent struct {
 	Code int
 	RequestTime time.Duration
 	RequestLength int
+	// How the response was classified
+	Verdict Verdict
 }
 
 func NewLoadEmitter(options *LoadEmitterOptions, proxy *url.URL) Emitter {
@@ -54,6 +55,7 @@ func NewLoadEmitter(options *LoadEmitterOptions, proxy *url.URL) Emitter {
 	emitter.proxy = proxy
 	//emitter.client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxy)}}
 	emitter.client = &fasthttp.HostClient{Addr: proxy.Host}
+	emitter.classifier = NewClassifier()
 	return emitter
 }
 //
@@ -125,21 +127,23 @@ func (e *LoadEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
 
 			start := time.Now()
 			err := e.client.Do(req, resp)
+			verdict := e.classifier.Classify(resp, err)
 			if err != nil {
-				log <- errors.New(fmt.Sprintf("Error: %s", err))
+				log <- &ClassifiedError{Verdict: verdict, Err: err}
 				//done <- struct{}{}
 				//return
-			}
-
-			ev := LoadEmitterEvent{
-				Code: resp.StatusCode(),
-				RequestTime: time.Since(start),
-				RequestLength: resp.Header.ContentLength(),
-			}
+			} else {
+				ev := LoadEmitterEvent{
+					Code: resp.StatusCode(),
+					RequestTime: time.Since(start),
+					RequestLength: resp.Header.ContentLength(),
+					Verdict: verdict,
+				}
 
-			//fmt.Printf("Event: %#v", ev)
+				//fmt.Printf("Event: %#v", ev)
 
-			log <- ev
+				log <- ev
+			}
 
 			select {
 			case _, ok := <-stop:
@@ -0,0 +1,274 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// GridEmitter sends every combination of header and body sizes, limits of
+// servers with a single buffer for the whole request depend on each other
+type GridEmitter struct {
+	*ProbeEmitter
+	grid *gridProbe
+
+	gridOptions *GridOptions
+}
+
+type GridOptions struct {
+	// Axes grow up to these sizes
+	HeaderMax, BodyMax int
+	// File acceptance map is written to, nothing is written if empty
+	Output string
+	// Output format: csv or json
+	Format string
+}
+
+// GridCell is the outcome of a single request of the grid
+type GridCell struct {
+	Header   int     `json:"header"`
+	Body     int     `json:"body"`
+	Verdict  Verdict `json:"-"`
+	Code     int     `json:"code"`
+	Accepted bool    `json:"accepted"`
+}
+
+func (c GridCell) MarshalJSON() ([]byte, error) {
+	type cell GridCell
+	return json.Marshal(struct {
+		cell
+		Verdict string `json:"verdict"`
+	}{cell(c), c.Verdict.String()})
+}
+
+// GridResult is sent by grid emitter when all the combinations are sent
+type GridResult struct {
+	Via         string     `json:"via,omitempty"`
+	HeaderSizes []int      `json:"header_sizes"`
+	BodySizes   []int      `json:"body_sizes"`
+	Cells       []GridCell `json:"cells"`
+	Interrupted bool       `json:"interrupted"`
+}
+
+// gridProbe puts both header and body into request, probed size is the cell number
+type gridProbe struct {
+	header, body GrowableContent
+	headerSizes  []int
+	bodySizes    []int
+	cell         int
+}
+
+func (p *gridProbe) Name() string {
+	return "header x body"
+}
+
+// Grow moves to the next cell, row by row
+func (p *gridProbe) Grow() (int, error) {
+	if p.cell >= len(p.headerSizes)*len(p.bodySizes) {
+		return p.cell, errors.New("Grid is over")
+	}
+	p.cell++
+	return p.cell - 1, nil
+}
+
+func (p *gridProbe) Prepare(req *fasthttp.Request, cell int) error {
+	header, body := p.sizes(cell)
+	payload, err := p.header.SetSize(uint(header))
+	if err != nil {
+		return err
+	}
+	req.Header.SetBytesV(RequestHeaderName, payload)
+	payload, err = p.body.SetSize(uint(body))
+	if err != nil {
+		return err
+	}
+	req.SetBody(payload)
+	return nil
+}
+
+// sizes returns header and body size of the cell
+func (p *gridProbe) sizes(cell int) (int, int) {
+	return p.headerSizes[cell/len(p.bodySizes)], p.bodySizes[cell%len(p.bodySizes)]
+}
+
+// axis returns sizes content grows through up to max
+func axis(content GrowableContent, max int) ([]int, error) {
+	var sizes []int
+	for {
+		payload, err := content.Grow()
+		if err != nil {
+			return nil, err
+		}
+		size := len(payload)
+		if size > max || len(sizes) > 0 && size <= sizes[len(sizes)-1] {
+			return sizes, nil
+		}
+		sizes = append(sizes, size)
+	}
+}
+
+func NewGridEmitter(options *ProbeEmitterOptions, gridOptions *GridOptions, header, body GrowableContent, proxy *url.URL) (Emitter, error) {
+	headerSizes, err := axis(header, gridOptions.HeaderMax)
+	if err != nil {
+		return nil, err
+	}
+	bodySizes, err := axis(body, gridOptions.BodyMax)
+	if err != nil {
+		return nil, err
+	}
+	if len(headerSizes) == 0 || len(bodySizes) == 0 {
+		return nil, errors.New("Grid is empty, initial sizes exceed max ones")
+	}
+	grid := &gridProbe{header: header, body: body, headerSizes: headerSizes, bodySizes: bodySizes}
+	return &GridEmitter{
+		ProbeEmitter: NewProbeEmitter(options, grid, proxy).(*ProbeEmitter),
+		grid:         grid,
+		gridOptions:  gridOptions,
+	}, nil
+}
+
+func (e *GridEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
+
+	result := GridResult{HeaderSizes: e.grid.headerSizes, BodySizes: e.grid.bodySizes}
+	if e.proxy != nil {
+		result.Via = e.proxy.Host
+	}
+
+	defer func() {
+		if e.gridOptions.Output != "" {
+			if err := result.WriteFile(e.gridOptions.Output, e.gridOptions.Format); err != nil {
+				log <- errors.New(fmt.Sprintf("Error: %s", err))
+			}
+		}
+		log <- result
+		done <- struct{}{}
+	}()
+
+	for requests := 0; ; {
+		if e.options.interrupted(stop, requests) {
+			result.Interrupted = true
+			return
+		}
+		cell, err := e.grid.Grow()
+		if err != nil {
+			return
+		}
+
+		var verdict Verdict
+		var code int
+		for attempt := 0; ; attempt++ {
+			verdict, code, err = e.send(cell, log)
+			if err != nil {
+				log <- errors.New(fmt.Sprintf("Error: %s", err))
+				return
+			}
+			requests++
+			if e.classifier.Meaning(verdict) != MeaningRetry || attempt >= e.classifier.Retries {
+				break
+			}
+		}
+
+		header, body := e.grid.sizes(cell)
+		result.Cells = append(result.Cells, GridCell{
+			Header:   header,
+			Body:     body,
+			Verdict:  verdict,
+			Code:     code,
+			Accepted: e.classifier.Meaning(verdict) == MeaningAccept,
+		})
+	}
+}
+
+// WriteFile writes acceptance map to the file in csv or json format
+func (r GridResult) WriteFile(name, format string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch format {
+	case "json":
+		return r.WriteJSON(f)
+	case "", "csv":
+		return r.WriteCSV(f)
+	}
+	return errors.New(fmt.Sprintf("Unknown grid format: %s", format))
+}
+
+func (r GridResult) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"header", "body", "verdict", "code", "accepted"})
+	for _, c := range r.Cells {
+		cw.Write([]string{strconv.Itoa(c.Header), strconv.Itoa(c.Body), c.Verdict.String(), strconv.Itoa(c.Code), strconv.FormatBool(c.Accepted)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r GridResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Print draws acceptance map, rows are header sizes and columns are body sizes
+func (r GridResult) Print() {
+	via := r.Via
+	if via == "" {
+		via = "direct"
+	}
+	fmt.Printf("Header x body map (%s):\n", via)
+
+	// accepted cells are '#', every rejecting verdict gets it's own letter
+	marks := make(map[Verdict]byte)
+	var legend []Verdict
+	cells := make(map[[2]int]GridCell, len(r.Cells))
+	for _, c := range r.Cells {
+		cells[[2]int{c.Header, c.Body}] = c
+		if _, ok := marks[c.Verdict]; !ok && !c.Accepted {
+			marks[c.Verdict] = byte('a' + len(legend))
+			legend = append(legend, c.Verdict)
+		}
+	}
+
+	fmt.Printf("%12s  ", "header\\body")
+	for i := range r.BodySizes {
+		fmt.Printf("%-3d", i)
+	}
+	fmt.Println()
+	for _, h := range r.HeaderSizes {
+		fmt.Printf("%12d  ", h)
+		for _, b := range r.BodySizes {
+			c, ok := cells[[2]int{h, b}]
+			switch {
+			case !ok:
+				fmt.Print("   ")
+			case c.Accepted:
+				fmt.Print("#  ")
+			default:
+				fmt.Printf("%c  ", marks[c.Verdict])
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("  # accepted")
+	for _, v := range legend {
+		fmt.Printf("  %c %s\n", marks[v], v)
+	}
+	fmt.Print("  body sizes:")
+	for i, b := range r.BodySizes {
+		fmt.Printf(" %d=%d", i, b)
+	}
+	fmt.Println()
+	if r.Interrupted {
+		fmt.Println("  interrupted, map is incomplete")
+	}
+}
@@ -0,0 +1,529 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Protocol is the http version requests are sent with
+type Protocol string
+
+const (
+	HTTP11 Protocol = "http/1.1"
+	// HTTP/2 over tls
+	H2 Protocol = "h2"
+	// HTTP/2 over plain connection with prior knowledge
+	H2C Protocol = "h2c"
+	// HTTP/3 over QUIC
+	H3 Protocol = "h3"
+)
+
+var Protocols = []Protocol{HTTP11, H2, H2C, H3}
+
+func ParseProtocol(s string) (Protocol, error) {
+	for _, p := range Protocols {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return HTTP11, errors.New(fmt.Sprintf("Unknown protocol: %s", s))
+}
+
+// ParseProtocols reads list of protocols, empty list means http/1.1 only
+func ParseProtocols(names []string) ([]Protocol, error) {
+	var protos []Protocol
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		p, err := ParseProtocol(name)
+		if err != nil {
+			return nil, err
+		}
+		protos = append(protos, p)
+	}
+	if len(protos) == 0 {
+		protos = append(protos, HTTP11)
+	}
+	return protos, nil
+}
+
+// default SETTINGS_INITIAL_WINDOW_SIZE and connection window
+const h2DefaultWindow = 65535
+
+// fatty is ready to receive as much as the server sends
+const h2ReceiveWindow = 1<<31 - 1
+
+// H2Client sends every request over a new HTTP/2 connection. It speaks the protocol
+// frame by frame, so limits advertised by the server are not enforced on client side,
+// the server is the one to reject too large requests.
+type H2Client struct {
+	Addr  string
+	IsTLS bool
+
+	// what the server has advertised on the last connection
+	Settings map[http2.SettingID]uint32
+	// connection window the server has granted on top of the initial one
+	ConnWindow int64
+	// number of window updates received while sending request bodies
+	WindowUpdates int
+}
+
+func NewH2Client(dest, proxy *url.URL, protocol Protocol) *H2Client {
+	if proxy != nil {
+		return &H2Client{Addr: addrWithPort(proxy), IsTLS: protocol == H2}
+	}
+	return &H2Client{Addr: addrWithPort(dest), IsTLS: protocol == H2}
+}
+
+func (c *H2Client) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	conn, err := c.Dial(timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	id := conn.NextStream()
+	body := req.BodyStream()
+	if body == nil && len(req.Body()) > 0 {
+		body = bytes.NewReader(req.Body())
+	}
+	if err = conn.WriteHeaders(id, req, body == nil); err != nil {
+		return err
+	}
+	if body != nil {
+		done, err := conn.WriteData(id, body, resp)
+		if err != nil || done {
+			return err
+		}
+	}
+	return conn.ReadResponse(id, resp)
+}
+
+// Summary describes what the server has advertised on the last connection
+func (c *H2Client) Summary() string {
+	if c.Settings == nil {
+		return "no http/2 settings received"
+	}
+	var settings []string
+	for id, value := range c.Settings {
+		settings = append(settings, fmt.Sprintf("%s=%d", id, value))
+	}
+	sort.Strings(settings)
+	return fmt.Sprintf("server settings: %s, connection window +%d, window updates: %d",
+		strings.Join(settings, " "), c.ConnWindow, c.WindowUpdates)
+}
+
+// HeaderListDetail compares header list sizes of the largest accepted and the smallest
+// rejected requests with advertised SETTINGS_MAX_HEADER_LIST_SIZE, -1 means there is no such request
+func (c *H2Client) HeaderListDetail(accepted, rejected int) string {
+	max, ok := c.Settings[http2.SettingMaxHeaderListSize]
+	switch {
+	case !ok && accepted >= 0:
+		return fmt.Sprintf("%s is not advertised, header list of %d bytes was accepted", http2.SettingMaxHeaderListSize, accepted)
+	case !ok:
+		return fmt.Sprintf("%s is not advertised", http2.SettingMaxHeaderListSize)
+	case accepted > int(max):
+		return fmt.Sprintf("header list of %d bytes was accepted, larger than advertised %s: %d", accepted, http2.SettingMaxHeaderListSize, max)
+	case rejected >= 0 && rejected <= int(max):
+		return fmt.Sprintf("header list of %d bytes was rejected, within advertised %s: %d", rejected, http2.SettingMaxHeaderListSize, max)
+	case rejected >= 0:
+		return fmt.Sprintf("header list limit matches advertised %s: %d, accepted %d bytes, rejected %d bytes", http2.SettingMaxHeaderListSize, max, accepted, rejected)
+	}
+	return fmt.Sprintf("header list of %d bytes was accepted, advertised %s: %d", accepted, http2.SettingMaxHeaderListSize, max)
+}
+
+// Dial opens connection and exchanges settings with the server
+func (c *H2Client) Dial(timeout time.Duration) (*H2Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if c.IsTLS {
+		tlsConn, err := tls.DialWithDialer(dialer, "tcp", c.Addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http2.NextProtoTLS}})
+		if err != nil {
+			return nil, err
+		}
+		if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+			tlsConn.Close()
+			return nil, errors.New("Server doesn't support h2")
+		}
+		conn = tlsConn
+	} else {
+		conn, err = dialer.Dial("tcp", c.Addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	w := bufio.NewWriter(conn)
+	framer := http2.NewFramer(w, bufio.NewReader(conn))
+	// fatty doesn't advertise SETTINGS_HEADER_TABLE_SIZE, so the default one is used
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	framer.MaxHeaderListSize = h2ReceiveWindow
+	h := &H2Conn{
+		conn:         conn,
+		client:       c,
+		w:            w,
+		framer:       framer,
+		settings:     make(map[http2.SettingID]uint32),
+		maxFrame:     16384,
+		initWindow:   h2DefaultWindow,
+		connWindow:   h2DefaultWindow,
+		windows:      make(map[uint32]int64),
+		responses:    make(map[uint32]*fasthttp.Response),
+		finished:     make(map[uint32]error),
+		nextStreamID: 1,
+	}
+	h.encoder = hpack.NewEncoder(&h.block)
+
+	if _, err = w.WriteString(http2.ClientPreface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	framer.WriteSettings(http2.Setting{ID: http2.SettingInitialWindowSize, Val: h2ReceiveWindow})
+	framer.WriteWindowUpdate(0, h2ReceiveWindow-h2DefaultWindow)
+	if err = w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// server's settings are the first frame it sends
+	for !h.settled {
+		if err = h.readFrame(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	c.Settings = h.settings
+	c.ConnWindow = 0
+	c.WindowUpdates = 0
+	return h, nil
+}
+
+// H2Conn is a connection opened by H2Client, several streams can be sent over it
+type H2Conn struct {
+	conn   net.Conn
+	client *H2Client
+	w      *bufio.Writer
+	framer *http2.Framer
+
+	encoder *hpack.Encoder
+	block   bytes.Buffer
+
+	settings map[http2.SettingID]uint32
+	settled  bool
+	maxFrame uint32
+	// send windows
+	initWindow, connWindow int64
+	windows                map[uint32]int64
+
+	// responses being read and errors streams are finished with, nil for successful ones
+	responses map[uint32]*fasthttp.Response
+	finished  map[uint32]error
+	pinged    bool
+
+	nextStreamID uint32
+}
+
+func (c *H2Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *H2Conn) Close() error {
+	return c.conn.Close()
+}
+
+// MaxFrameSize returns the max size of frame payload the server accepts
+func (c *H2Conn) MaxFrameSize() int {
+	return int(c.maxFrame)
+}
+
+// NextStream returns id of a new stream
+func (c *H2Conn) NextStream() uint32 {
+	id := c.nextStreamID
+	c.nextStreamID += 2
+	c.windows[id] = c.initWindow
+	return id
+}
+
+// Window returns number of bytes which can be sent over the stream without waiting for window update
+func (c *H2Conn) Window(id uint32) int {
+	return int(minInt64(c.connWindow, c.windows[id]))
+}
+
+// WriteHeaders sends request header block, split into frames the server accepts
+func (c *H2Conn) WriteHeaders(id uint32, req *fasthttp.Request, endStream bool) error {
+	c.block.Reset()
+	for _, field := range h2HeaderFields(req, endStream) {
+		c.encoder.WriteField(field)
+	}
+
+	block := c.block.Bytes()
+	first := true
+	for first || len(block) > 0 {
+		fragment := block[:minInt(len(block), int(c.maxFrame))]
+		block = block[len(fragment):]
+		var err error
+		if first {
+			err = c.framer.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      id,
+				BlockFragment: fragment,
+				EndStream:     endStream,
+				EndHeaders:    len(block) == 0,
+			})
+			first = false
+		} else {
+			err = c.framer.WriteContinuation(id, len(block) == 0, fragment)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+// h2HeaderFields returns header fields request is sent with, body is expected to follow unless endStream is set
+func h2HeaderFields(req *fasthttp.Request, endStream bool) []hpack.HeaderField {
+	uri := req.URI()
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: string(req.Header.Method())},
+		{Name: ":scheme", Value: string(uri.Scheme())},
+		{Name: ":authority", Value: string(uri.Host())},
+		{Name: ":path", Value: string(uri.RequestURI())},
+	}
+	req.Header.VisitAll(func(key, value []byte) {
+		switch name := strings.ToLower(string(key)); name {
+		case "host", "content-length", "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+			// connection specific headers are not allowed in http/2, content length is set below
+		default:
+			fields = append(fields, hpack.HeaderField{Name: name, Value: string(value)})
+		}
+	})
+	// body must not be touched if it's streamed, reading it consumes the stream
+	length := req.Header.ContentLength()
+	if !req.IsBodyStream() {
+		length = len(req.Body())
+	}
+	if length >= 0 && !endStream {
+		fields = append(fields, hpack.HeaderField{Name: "content-length", Value: fmt.Sprint(length)})
+	}
+	return fields
+}
+
+// H2HeaderListSize returns size of request header list as SETTINGS_MAX_HEADER_LIST_SIZE counts it
+func H2HeaderListSize(req *fasthttp.Request) int {
+	endStream := !req.IsBodyStream() && len(req.Body()) == 0
+	size := 0
+	for _, field := range h2HeaderFields(req, endStream) {
+		size += int(field.Size())
+	}
+	return size
+}
+
+// WriteData sends body in frames as big as the server accepts, waiting for window updates
+// when needed. It returns true if the server has finished the stream before the body was sent.
+func (c *H2Conn) WriteData(id uint32, body io.Reader, resp *fasthttp.Response) (bool, error) {
+	c.responses[id] = resp
+	buf := make([]byte, c.maxFrame)
+	for {
+		for c.connWindow <= 0 || c.windows[id] <= 0 {
+			if done, err := c.wait(id); done || err != nil {
+				return done, err
+			}
+		}
+		size := minInt(len(buf), int(minInt64(c.connWindow, c.windows[id])))
+		n, err := io.ReadFull(body, buf[:size])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if err = c.framer.WriteData(id, true, buf[:n]); err != nil {
+				return false, err
+			}
+			return false, c.w.Flush()
+		}
+		if err != nil {
+			return false, err
+		}
+		if err = c.framer.WriteData(id, false, buf[:n]); err != nil {
+			return false, err
+		}
+		if err = c.w.Flush(); err != nil {
+			return false, err
+		}
+		c.connWindow -= int64(n)
+		c.windows[id] -= int64(n)
+	}
+}
+
+// WriteFrameData sends a single data frame of any size, regardless of the server's limits
+func (c *H2Conn) WriteFrameData(id uint32, data []byte, endStream bool) error {
+	if err := c.framer.WriteData(id, endStream, data); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// ReadResponse reads frames until the stream is finished
+func (c *H2Conn) ReadResponse(id uint32, resp *fasthttp.Response) error {
+	c.responses[id] = resp
+	for {
+		if done, err := c.wait(id); done || err != nil {
+			return err
+		}
+	}
+}
+
+// Ping sends ping and reads frames until it's acknowledged, so every frame
+// the server has sent in response to the previous ones is read
+func (c *H2Conn) Ping() error {
+	if err := c.framer.WritePing(false, [8]byte{'f', 'a', 't', 't', 'y'}); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	c.pinged = false
+	for !c.pinged {
+		if err := c.readFrame(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finished tells whether the stream is finished and returns error it was finished with
+func (c *H2Conn) Finished(id uint32) (bool, error) {
+	err, ok := c.finished[id]
+	return ok, err
+}
+
+// wait reads the next frame and tells whether the stream is finished
+func (c *H2Conn) wait(id uint32) (bool, error) {
+	if err := c.readFrame(); err != nil {
+		return false, err
+	}
+	err, done := c.finished[id]
+	return done, err
+}
+
+func (c *H2Conn) readFrame() error {
+	frame, err := c.framer.ReadFrame()
+	if err != nil {
+		return err
+	}
+	id := frame.Header().StreamID
+	switch f := frame.(type) {
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			return nil
+		}
+		f.ForeachSetting(func(s http2.Setting) error {
+			c.settings[s.ID] = s.Val
+			switch s.ID {
+			case http2.SettingMaxFrameSize:
+				c.maxFrame = s.Val
+			case http2.SettingHeaderTableSize:
+				// encoder may use as large dynamic table as the server decodes
+				c.encoder.SetMaxDynamicTableSizeLimit(s.Val)
+			case http2.SettingInitialWindowSize:
+				for stream := range c.windows {
+					c.windows[stream] += int64(s.Val) - c.initWindow
+				}
+				c.initWindow = int64(s.Val)
+			}
+			return nil
+		})
+		c.settled = true
+		c.framer.WriteSettingsAck()
+		return c.w.Flush()
+	case *http2.PingFrame:
+		if f.IsAck() {
+			c.pinged = true
+			return nil
+		}
+		c.framer.WritePing(true, f.Data)
+		return c.w.Flush()
+	case *http2.WindowUpdateFrame:
+		if id == 0 {
+			c.connWindow += int64(f.Increment)
+			c.client.ConnWindow += int64(f.Increment)
+		} else {
+			c.windows[id] += int64(f.Increment)
+		}
+		c.client.WindowUpdates++
+	case *http2.GoAwayFrame:
+		err := http2.GoAwayError{LastStreamID: f.LastStreamID, ErrCode: f.ErrCode, DebugData: string(f.DebugData())}
+		for stream := range c.windows {
+			if _, ok := c.finished[stream]; !ok && stream > f.LastStreamID {
+				c.finished[stream] = err
+			}
+		}
+		if f.ErrCode != http2.ErrCodeNo {
+			return err
+		}
+	case *http2.RSTStreamFrame:
+		c.finished[id] = http2.StreamError{StreamID: id, Code: f.ErrCode}
+	case *http2.MetaHeadersFrame:
+		if resp, ok := c.responses[id]; ok {
+			for _, field := range f.Fields {
+				if field.Name == ":status" {
+					code := 0
+					fmt.Sscan(field.Value, &code)
+					resp.SetStatusCode(code)
+				} else if !field.IsPseudo() {
+					resp.Header.Add(field.Name, field.Value)
+				}
+			}
+		}
+		if f.StreamEnded() {
+			c.finished[id] = nil
+		}
+	case *http2.DataFrame:
+		if resp, ok := c.responses[id]; ok {
+			resp.AppendBody(f.Data())
+		}
+		if n := uint32(len(f.Data())); n > 0 {
+			// receive windows are kept full
+			c.framer.WriteWindowUpdate(0, n)
+			c.framer.WriteWindowUpdate(id, n)
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+		}
+		if f.StreamEnded() {
+			c.finished[id] = nil
+		}
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,200 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+// H2LimitsEmitter finds limits of HTTP/2 connection the server enforces,
+// as opposed to ones it only advertises
+type H2LimitsEmitter struct {
+	client     *H2Client
+	proxy      *url.URL
+	classifier *Classifier
+
+	options   *ProbeEmitterOptions
+	h2Options *H2Options
+}
+
+type H2Options struct {
+	// Stop opening streams after this number of them
+	MaxStreams int
+}
+
+func NewH2LimitsEmitter(options *ProbeEmitterOptions, h2Options *H2Options, proxy *url.URL) Emitter {
+	emitter := &H2LimitsEmitter{}
+	emitter.options = options
+	emitter.h2Options = h2Options
+	emitter.proxy = proxy
+	emitter.client = NewH2Client(options.Dest, proxy, options.Proto)
+	emitter.classifier = options.classifier()
+	return emitter
+}
+
+func (e *H2LimitsEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
+	defer func() {
+		done <- struct{}{}
+	}()
+	log <- e.streams(stop, log)
+	log <- e.frameSize(stop, log)
+}
+
+func (e *H2LimitsEmitter) newResult(name, unit string) ProbeResult {
+	result := newProbeResult(name, e.proxy)
+	result.Proto, result.Unit = e.options.Proto, unit
+	return result
+}
+
+func (e *H2LimitsEmitter) request(length int) *fasthttp.Request {
+	req := fasthttp.AcquireRequest()
+	req.SetRequestURI(e.options.Dest.String())
+	req.Header.SetMethod(fasthttp.MethodPost)
+	setProxyAuthorization(req, e.proxy)
+	req.SetBodyStream(bytes.NewReader(nil), length)
+	return req
+}
+
+// streams opens streams over a single connection one by one and leaves every of them
+// waiting for it's body, until the server refuses the next one
+func (e *H2LimitsEmitter) streams(stop chan struct{}, log chan EmitterEvent) ProbeResult {
+
+	result := e.newResult("h2 concurrent streams", "streams")
+	conn, err := e.client.Dial(e.options.RequestTimeout)
+	if err != nil {
+		result.Rejected, result.Verdict = 1, classifyError(err)
+		log <- &ClassifiedError{Verdict: result.Verdict, Err: err}
+		return result
+	}
+	defer conn.Close()
+
+	req := e.request(1)
+	defer fasthttp.ReleaseRequest(req)
+	for n := 1; n <= e.h2Options.MaxStreams; n++ {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			break
+		}
+		conn.SetDeadline(time.Now().Add(e.options.RequestTimeout))
+		id := conn.NextStream()
+		result.Requests++
+		// ping is answered after the server has handled the new stream
+		if err = conn.WriteHeaders(id, req, false); err == nil {
+			err = conn.Ping()
+		}
+		if err == nil {
+			_, err = conn.Finished(id)
+		}
+		if err != nil {
+			verdict := classifyError(err)
+			log <- &ClassifiedError{Verdict: verdict, Err: err}
+			result.Rejected, result.Verdict = n, verdict
+			result.Details = append(result.Details, fmt.Sprintf("stream %d was finished with: %s", n, err))
+			break
+		}
+		result.Accepted = n
+	}
+	result.Confirmed = true
+
+	if max, ok := e.client.Settings[http2.SettingMaxConcurrentStreams]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("advertised %s: %d", http2.SettingMaxConcurrentStreams, max))
+	} else {
+		result.Details = append(result.Details, fmt.Sprintf("%s is not advertised", http2.SettingMaxConcurrentStreams))
+	}
+	return result
+}
+
+// frameSize sends body in a single data frame of advertised max size and in a frame
+// one byte larger, the latter must be rejected with FRAME_SIZE_ERROR
+func (e *H2LimitsEmitter) frameSize(stop chan struct{}, log chan EmitterEvent) ProbeResult {
+
+	result := e.newResult("h2 frame size", "")
+	for result.Rejected < 0 {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			return result
+		}
+		size := 16384
+		if result.Accepted > 0 {
+			size = result.Accepted + 1
+		} else if max, ok := e.client.Settings[http2.SettingMaxFrameSize]; ok {
+			size = int(max)
+		}
+
+		verdict, code, window, err := e.sendFrame(size, log)
+		if err != nil {
+			log <- errors.New(fmt.Sprintf("Error: %s", err))
+			return result
+		}
+		if window < size {
+			// larger frame would be rejected for exceeding flow control window, not for it's size
+			result.Details = append(result.Details, fmt.Sprintf("frame of %d bytes doesn't fit into flow control window of %d bytes", size, window))
+			break
+		}
+		result.Requests++
+		if e.classifier.Meaning(verdict) != MeaningAccept {
+			result.Rejected, result.Verdict, result.Code = size, verdict, code
+			break
+		}
+		result.Accepted = size
+		if result.Requests > 1 {
+			result.Details = append(result.Details, "frame larger than advertised max size was accepted")
+			break
+		}
+	}
+	result.Confirmed = true
+	result.Details = append(result.Details, e.client.Summary())
+	return result
+}
+
+// sendFrame sends request with body of given size in a single data frame, nothing is sent
+// if the frame doesn't fit into returned flow control window. Error is returned only
+// if connection could not be opened.
+func (e *H2LimitsEmitter) sendFrame(size int, log chan EmitterEvent) (Verdict, int, int, error) {
+
+	start := time.Now()
+	conn, err := e.client.Dial(e.options.RequestTimeout)
+	if err != nil {
+		return VerdictError, 0, 0, err
+	}
+	defer conn.Close()
+	// window updates sent along with settings are read before ping is acknowledged
+	if err = conn.Ping(); err != nil {
+		return VerdictError, 0, 0, err
+	}
+	id := conn.NextStream()
+	window := conn.Window(id)
+	if window < size {
+		return VerdictError, 0, window, nil
+	}
+
+	req := e.request(size)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	err = conn.WriteHeaders(id, req, false)
+	if err == nil {
+		err = conn.WriteFrameData(id, bytes.Repeat([]byte{'a'}, size), true)
+	}
+	if err == nil {
+		err = conn.ReadResponse(id, resp)
+	}
+	verdict := e.classifier.Classify(resp, err)
+	if err != nil {
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return verdict, 0, window, nil
+	}
+	log <- LoadEmitterEvent{
+		Code:          resp.StatusCode(),
+		RequestTime:   time.Since(start),
+		RequestLength: resp.Header.ContentLength(),
+		Verdict:       verdict,
+	}
+	return verdict, resp.StatusCode(), window, nil
+}
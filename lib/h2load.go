@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"crypto/tls"
+	"net"
+
+	"golang.org/x/net/http2"
+)
+
+// NewH2Pool returns pool of HTTP/2 connections to addr, every one of them
+// carries up to streams concurrent streams
+func NewH2Pool(addr string, protocol Protocol, conns, streams int) *Pool {
+	transport := &http2.Transport{AllowHTTP: true}
+	return NewPool(conns, streams, func() (PoolConn, error) {
+		var conn net.Conn
+		var err error
+		if protocol == H2 {
+			conn, err = tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http2.NextProtoTLS}})
+		} else {
+			conn, err = net.Dial("tcp", addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cc, err := transport.NewClientConn(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return h2PoolConn{cc}, nil
+	})
+}
+
+type h2PoolConn struct {
+	*http2.ClientConn
+}
+
+// Closed tells whether connection is closed or is being closed, e.g. after GOAWAY
+func (c h2PoolConn) Closed() bool {
+	state := c.State()
+	return state.Closed || state.Closing
+}
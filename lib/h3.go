@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/valyala/fasthttp"
+)
+
+// H3Client sends every request over a new HTTP/3 connection
+type H3Client struct {
+	Addr string
+}
+
+func NewH3Client(dest, proxy *url.URL) *H3Client {
+	if proxy != nil {
+		return &H3Client{Addr: addrWithPort(proxy)}
+	}
+	return &H3Client{Addr: addrWithPort(dest)}
+}
+
+func (c *H3Client) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	transport := NewH3Transport(c.Addr)
+	defer transport.Close()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	r, err := newHTTPRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	httpResp, err := transport.RoundTrip(r)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	resp.SetStatusCode(httpResp.StatusCode)
+	for name, values := range httpResp.Header {
+		for _, value := range values {
+			resp.Header.Add(name, value)
+		}
+	}
+	body, err := ioutil.ReadAll(httpResp.Body)
+	resp.SetBody(body)
+	return err
+}
+
+// NewH3Transport returns HTTP/3 transport which connects to addr whatever request url is
+func NewH3Transport(addr string) *http3.Transport {
+	return &http3.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		Dial: func(ctx context.Context, _ string, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error) {
+			return quic.DialAddr(ctx, addr, tlsConf, conf)
+		},
+	}
+}
+
+// NewH3Pool returns pool of HTTP/3 connections to addr, every one of them
+// carries up to streams concurrent streams
+func NewH3Pool(addr string, conns, streams int) *Pool {
+	return NewPool(conns, streams, func() (PoolConn, error) {
+		return h3PoolConn{NewH3Transport(addr)}, nil
+	})
+}
+
+// h3PoolConn is a transport with a single connection, it's reopened by the transport itself
+type h3PoolConn struct {
+	*http3.Transport
+}
+
+func (c h3PoolConn) RoundTrip(req *http.Request) (*http.Response, error) {
+	// HTTP/3 is always secure
+	if req.URL.Scheme != "https" {
+		u := *req.URL
+		u.Scheme = "https"
+		req.URL = &u
+	}
+	return c.Transport.RoundTrip(req)
+}
+
+func (c h3PoolConn) Closed() bool {
+	return false
+}
+
+// newHTTPRequest converts request to net/http one with https scheme,
+// streamed body is passed as is
+func newHTTPRequest(ctx context.Context, req *fasthttp.Request) (*http.Request, error) {
+	u, err := url.Parse(req.URI().String())
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = "https"
+
+	var body io.Reader
+	length := int64(req.Header.ContentLength())
+	if req.IsBodyStream() {
+		body = req.BodyStream()
+	} else if len(req.Body()) > 0 {
+		body = bytes.NewReader(req.Body())
+		length = int64(len(req.Body()))
+	}
+	r, err := http.NewRequestWithContext(ctx, string(req.Header.Method()), u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.ContentLength = length
+	}
+	req.Header.VisitAll(func(key, value []byte) {
+		switch name := strings.ToLower(string(key)); name {
+		case "host", "content-length", "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+			// connection specific headers are not allowed in http/3, the rest is set by the transport
+		default:
+			r.Header.Add(string(key), string(value))
+		}
+	})
+	return r, nil
+}
@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// KeepAliveEmitter looks for connection level limits over a single connection it opens
+// itself: how long idle connection is kept open or how many requests are served over it
+type KeepAliveEmitter struct {
+	client     *RawClient
+	proxy      *url.URL
+	classifier *Classifier
+	// search for idle timeout if set, for max requests per connection otherwise
+	idle bool
+
+	options          *ProbeEmitterOptions
+	keepAliveOptions *KeepAliveOptions
+}
+
+type KeepAliveOptions struct {
+	// How long to wait for idle connection to be closed
+	MaxIdle time.Duration
+	// Stop sending requests over connection after this number of them
+	MaxRequests int
+}
+
+// NewIdleTimeoutEmitter searches for the time idle keep-alive connection is closed after
+func NewIdleTimeoutEmitter(options *ProbeEmitterOptions, keepAliveOptions *KeepAliveOptions, proxy *url.URL) Emitter {
+	e := newKeepAliveEmitter(options, keepAliveOptions, proxy)
+	e.idle = true
+	return e
+}
+
+// NewConnRequestsEmitter searches for max number of requests served over a single connection
+func NewConnRequestsEmitter(options *ProbeEmitterOptions, keepAliveOptions *KeepAliveOptions, proxy *url.URL) Emitter {
+	return newKeepAliveEmitter(options, keepAliveOptions, proxy)
+}
+
+func newKeepAliveEmitter(options *ProbeEmitterOptions, keepAliveOptions *KeepAliveOptions, proxy *url.URL) *KeepAliveEmitter {
+	emitter := &KeepAliveEmitter{}
+	emitter.options = options
+	emitter.keepAliveOptions = keepAliveOptions
+	emitter.proxy = proxy
+	emitter.client = NewRawClient(options.Dest, proxy)
+	emitter.classifier = options.classifier()
+	return emitter
+}
+
+func (e *KeepAliveEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
+
+	var result ProbeResult
+	if e.idle {
+		result = newProbeResult("keep-alive idle timeout", e.proxy)
+		result.Unit = "ms"
+	} else {
+		result = newProbeResult("requests per connection", e.proxy)
+		result.Unit = "requests"
+	}
+
+	defer func() {
+		log <- result
+		done <- struct{}{}
+	}()
+
+	conn, err := e.client.Dial(e.options.RequestTimeout)
+	if err != nil {
+		log <- &ClassifiedError{Verdict: classifyError(err), Err: err}
+		return
+	}
+	defer conn.Close()
+
+	// waiting for the server may take long, so connection is closed on stop
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-finished:
+		}
+	}()
+
+	if e.idle {
+		e.waitIdle(stop, conn, &result, log)
+	} else {
+		e.countRequests(stop, conn, &result, log)
+	}
+}
+
+// waitIdle sends a single request and waits until the server closes connection
+func (e *KeepAliveEmitter) waitIdle(stop chan struct{}, conn *RawConn, result *ProbeResult, log chan EmitterEvent) {
+	verdict, code, closed := e.send(conn, log)
+	result.Requests++
+	if e.classifier.Meaning(verdict) != MeaningAccept {
+		result.Rejected, result.Verdict, result.Code = 0, verdict, code
+		return
+	}
+	if closed {
+		result.Rejected, result.Verdict, result.Code = 0, VerdictReset, code
+		result.Details = append(result.Details, "server closes connection after every response")
+		return
+	}
+
+	idle, err := conn.WaitClose(e.keepAliveOptions.MaxIdle)
+	result.Accepted = int(idle / time.Millisecond)
+	stopped := errors.Is(err, net.ErrClosed)
+	select {
+	case <-stop:
+		stopped = true
+	default:
+	}
+	switch {
+	case err == nil:
+		result.Rejected = result.Accepted + 1
+		result.Verdict = VerdictReset
+		result.Confirmed = true
+		result.Details = append(result.Details, fmt.Sprintf("idle connection closed by server after %s", idle))
+	case err == ErrIdleData:
+		e.readIdle(conn, idle, result, log)
+	case stopped:
+		// connection is closed locally on stop
+		result.Interrupted = true
+		result.Details = append(result.Details, fmt.Sprintf("stopped after waiting on idle connection for %s", idle))
+	case classifyError(err) == VerdictTimeout:
+		result.Details = append(result.Details, fmt.Sprintf("idle connection was kept open for %s", idle))
+	default:
+		result.Interrupted = true
+		result.Details = append(result.Details, fmt.Sprintf("waiting on idle connection failed after %s: %s", idle, err))
+	}
+}
+
+// readIdle reads what the server sent to idle connection instead of closing it,
+// usually it's a timeout response sent right before the close
+func (e *KeepAliveEmitter) readIdle(conn *RawConn, idle time.Duration, result *ProbeResult, log chan EmitterEvent) {
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	conn.SetDeadline(time.Now().Add(e.options.RequestTimeout))
+	err := conn.ReadResponse(resp)
+	result.Rejected = result.Accepted + 1
+	result.Verdict = e.classifier.Classify(resp, err)
+	result.Interrupted = true
+	if err != nil {
+		log <- &ClassifiedError{Verdict: result.Verdict, Err: err}
+		result.Details = append(result.Details, fmt.Sprintf("server sent unexpected data to idle connection after %s", idle))
+		return
+	}
+	result.Code = resp.StatusCode()
+	log <- LoadEmitterEvent{Code: result.Code, RequestLength: resp.Header.ContentLength(), Verdict: result.Verdict}
+	result.Details = append(result.Details, fmt.Sprintf("server sent response with code %d to idle connection after %s instead of closing it", result.Code, idle))
+}
+
+// countRequests sends requests one by one until the server closes connection
+func (e *KeepAliveEmitter) countRequests(stop chan struct{}, conn *RawConn, result *ProbeResult, log chan EmitterEvent) {
+	for n := 1; n <= e.keepAliveOptions.MaxRequests; n++ {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			return
+		}
+
+		verdict, code, closed := e.send(conn, log)
+		result.Requests++
+		result.Confirmed = true
+		if e.classifier.Meaning(verdict) != MeaningAccept {
+			result.Accepted, result.Rejected, result.Verdict, result.Code = n-1, n, verdict, code
+			return
+		}
+		result.Accepted = n
+		if closed {
+			result.Rejected, result.Verdict, result.Code = n+1, VerdictReset, code
+			result.Details = append(result.Details, fmt.Sprintf("server closed connection after %d requests", n))
+			return
+		}
+	}
+}
+
+// send performs a single request over the connection, it's last return value tells
+// whether the server is closing connection after it
+func (e *KeepAliveEmitter) send(conn *RawConn, log chan EmitterEvent) (Verdict, int, bool) {
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(e.options.Dest.String())
+	req.Header.SetMethod(e.options.Method)
+	setProxyAuthorization(req, e.proxy)
+
+	start := time.Now()
+	err := conn.DoTimeout(req, resp, e.options.RequestTimeout)
+	verdict := e.classifier.Classify(resp, err)
+	if err != nil {
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return verdict, 0, true
+	}
+
+	log <- LoadEmitterEvent{
+		Code:          resp.StatusCode(),
+		RequestTime:   time.Since(start),
+		RequestLength: resp.Header.ContentLength(),
+		Verdict:       verdict,
+	}
+	return verdict, resp.StatusCode(), resp.ConnectionClose()
+}
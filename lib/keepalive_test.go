@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// runIdleTimeout runs idle timeout emitter against given address, stop is closed after given delay
+func runIdleTimeout(t *testing.T, addr string, stopAfter time.Duration) ProbeResult {
+	dest, _ := url.Parse("http://" + addr + "/")
+	options := &ProbeEmitterOptions{Dest: dest, Method: "GET", RequestTimeout: time.Second}
+	e := NewIdleTimeoutEmitter(options, &KeepAliveOptions{MaxIdle: 2 * time.Second}, nil)
+
+	stop := make(chan struct{})
+	done := make(chan struct{}, 1)
+	log := make(chan EmitterEvent, 100)
+	if stopAfter > 0 {
+		time.AfterFunc(stopAfter, func() { close(stop) })
+	}
+	e.Start(stop, done, log)
+	close(log)
+	for event := range log {
+		if result, ok := event.(ProbeResult); ok {
+			return result
+		}
+	}
+	t.Fatal("idle timeout emitter sent no result")
+	return ProbeResult{}
+}
+
+// serveRaw accepts a single connection, answers the first request and then calls idle
+func serveRaw(t *testing.T, idle func(net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		req.Body.Close()
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+		idle(conn)
+	}()
+	return l.Addr().String()
+}
+
+func TestIdleTimeoutEmitterClosed(t *testing.T) {
+	addr := serveRaw(t, func(conn net.Conn) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	r := runIdleTimeout(t, addr, 0)
+	if !r.Confirmed || r.Interrupted || r.Accepted < 200 || r.Rejected != r.Accepted+1 {
+		t.Errorf("accepted %d, rejected %d, confirmed %v, interrupted %v, want about 200 ms confirmed",
+			r.Accepted, r.Rejected, r.Confirmed, r.Interrupted)
+	}
+}
+
+func TestIdleTimeoutEmitterStopped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	r := runIdleTimeout(t, server.Listener.Addr().String(), 300*time.Millisecond)
+	if r.Confirmed || !r.Interrupted || r.Rejected >= 0 {
+		t.Errorf("accepted %d, rejected %d, confirmed %v, interrupted %v, want interrupted search without limit",
+			r.Accepted, r.Rejected, r.Confirmed, r.Interrupted)
+	}
+	if r.Requests != 1 {
+		t.Errorf("%d requests counted, want 1", r.Requests)
+	}
+}
+
+func TestIdleTimeoutEmitterResponse(t *testing.T) {
+	addr := serveRaw(t, func(conn net.Conn) {
+		time.Sleep(200 * time.Millisecond)
+		conn.Write([]byte("HTTP/1.1 408 Request Timeout\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+	})
+	r := runIdleTimeout(t, addr, 0)
+	if r.Confirmed || !r.Interrupted || r.Accepted < 200 || r.Rejected != r.Accepted+1 || r.Code != http.StatusRequestTimeout {
+		t.Errorf("accepted %d, rejected %d, code %d, confirmed %v, interrupted %v, want about 200 ms with code 408",
+			r.Accepted, r.Rejected, r.Code, r.Confirmed, r.Interrupted)
+	}
+	if r.Limit() == "-" || len(r.Details) == 0 {
+		t.Errorf("limit %q with details %q, want the reason search stopped", r.Limit(), r.Details)
+	}
+}
@@ -0,0 +1,231 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Mode decides what kind of bytes generated payload consists of
+type Mode string
+
+const (
+	ModeAlnum          Mode = "alnum"
+	ModeZeros          Mode = "zeros"
+	ModeBinary         Mode = "binary"
+	ModeUTF8           Mode = "utf8"
+	ModeObsText        Mode = "obs-text"
+	ModeJSON           Mode = "json"
+	ModeCompressible   Mode = "compressible"
+	ModeIncompressible Mode = "incompressible"
+)
+
+var Modes = []Mode{
+	ModeAlnum, ModeZeros, ModeBinary, ModeUTF8, ModeObsText, ModeJSON, ModeCompressible, ModeIncompressible,
+}
+
+func ParseMode(s string) (Mode, error) {
+	for _, m := range Modes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return ModeAlnum, errors.New(fmt.Sprintf("Unknown content mode: %s", s))
+}
+
+// ParseModes reads comma separated list of modes, empty list means alnum only
+func ParseModes(s string) ([]Mode, error) {
+	var modes []Mode
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		m, err := ParseMode(name)
+		if err != nil {
+			return nil, err
+		}
+		modes = append(modes, m)
+	}
+	if len(modes) == 0 {
+		modes = append(modes, ModeAlnum)
+	}
+	return modes, nil
+}
+
+// headerUnsafeModes produce control bytes, e.g. NUL or CRLF, which make header
+// malformed or split it, so the server rejects them regardless of size
+var headerUnsafeModes = map[Mode]bool{
+	ModeZeros:          true,
+	ModeBinary:         true,
+	ModeIncompressible: true,
+}
+
+// ParseHeaderModes reads comma separated list of modes header values are generated in
+func ParseHeaderModes(s string) ([]Mode, error) {
+	modes, err := ParseModes(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range modes {
+		if headerUnsafeModes[m] {
+			return nil, errors.New(fmt.Sprintf("Content mode %s produces control bytes and can't be used in headers", m))
+		}
+	}
+	return modes, nil
+}
+
+// cookieSafeModes produce only cookie-octets, values with quotes, spaces, commas,
+// semicolons, backslashes or non-ascii bytes are silently dropped by cookie parsers
+var cookieSafeModes = map[Mode]bool{
+	ModeAlnum:        true,
+	ModeCompressible: true,
+}
+
+// ParseCookieModes reads comma separated list of modes cookie values are generated in
+func ParseCookieModes(s string) ([]Mode, error) {
+	modes, err := ParseModes(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range modes {
+		if !cookieSafeModes[m] {
+			return nil, errors.New(fmt.Sprintf("Content mode %s produces bytes not allowed in cookie values", m))
+		}
+	}
+	return modes, nil
+}
+
+// ModalContent reports the mode it's payload is generated in
+type ModalContent interface {
+	Mode() Mode
+}
+
+// generator produces payload byte by byte, every byte depends only on it's position
+// and content key, so payload can be extended or truncated at any point and a request
+// of any size is replayed from the seed alone.
+type generator struct {
+	byteAt func(key uint64, pos uint) byte
+	// seal makes payload of size n valid as a whole, tail holds it's last bytes
+	seal func(tail []byte, n uint)
+	// how many last bytes seal may change
+	tailSize uint
+	// structured payload is prefix followed by repeated units of period bytes
+	prefix, period uint
+}
+
+func (m Mode) generator() generator {
+	switch m {
+	case ModeZeros:
+		return plainGenerator(func(key uint64, pos uint) byte { return 0 })
+	case ModeBinary:
+		// every byte value in turn
+		return plainGenerator(func(key uint64, pos uint) byte { return byte(pos) })
+	case ModeUTF8:
+		return utf8Generator
+	case ModeObsText:
+		return plainGenerator(func(key uint64, pos uint) byte { return byte(0x80 + randomAt(key, pos, 0x80)) })
+	case ModeJSON:
+		return jsonGenerator
+	case ModeCompressible:
+		// long runs of the same letter
+		return plainGenerator(func(key uint64, pos uint) byte { return letterBytes[pos/1024%uint(len(letterBytes))] })
+	case ModeIncompressible:
+		return plainGenerator(func(key uint64, pos uint) byte { return byte(randomAt(key, pos, 0x100)) })
+	}
+	return plainGenerator(func(key uint64, pos uint) byte { return letterBytes[randomAt(key, pos, len(letterBytes))] })
+}
+
+func plainGenerator(byteAt func(key uint64, pos uint) byte) generator {
+	return generator{byteAt: byteAt, seal: func([]byte, uint) {}, period: 1}
+}
+
+// fill generates payload bytes for positions starting at offset
+func (g generator) fill(key uint64, b []byte, offset uint) {
+	for i := range b {
+		b[i] = g.byteAt(key, offset+uint(i))
+	}
+}
+
+// randomAt returns random number in [0, n) for position of payload generated from key,
+// it's the pos-th number of splitmix64 sequence started from key
+func randomAt(key uint64, pos uint, n int) int {
+	return int(splitmix64(key+uint64(pos)*0x9E3779B97F4A7C15) % uint64(n))
+}
+
+// utf8Generator repeats 1, 2, 3 and 4 byte runes. Lead and continuation bytes are chosen
+// from ranges where any combination of them is a valid rune, so a rune regenerated
+// partially is still valid.
+var utf8Generator = generator{
+	byteAt: func(key uint64, pos uint) byte {
+		switch pos % 10 {
+		case 0:
+			return letterBytes[randomAt(key, pos, len(letterBytes))]
+		case 1:
+			return byte(0xC2 + randomAt(key, pos, 0xDF-0xC2+1))
+		case 3:
+			return byte(0xE1 + randomAt(key, pos, 0xEC-0xE1+1))
+		case 6:
+			return byte(0xF1 + randomAt(key, pos, 0xF3-0xF1+1))
+		}
+		return byte(0x80 + randomAt(key, pos, 0x40))
+	},
+	seal: func(tail []byte, n uint) {
+		// replace rune cut by payload end with ascii
+		var cut uint
+		switch n % 10 {
+		case 2:
+			cut = 1
+		case 4, 5:
+			cut = n%10 - 3
+		case 7, 8, 9:
+			cut = n%10 - 6
+		}
+		for i := uint(len(tail)) - cut; i < uint(len(tail)); i++ {
+			tail[i] = 'a'
+		}
+	},
+	tailSize: 3,
+	period:   10,
+}
+
+// jsonGenerator produces object with string members: {"kkkkkk":"vvvvvvvvvvvvvvvv",...}
+var jsonGenerator = generator{
+	byteAt: func(key uint64, pos uint) byte {
+		if pos == 0 {
+			return '{'
+		}
+		switch q := (pos - 1) % jsonMemberSize; {
+		case q == 0 || q == 7 || q == 9 || q == 26:
+			return '"'
+		case q == 8:
+			return ':'
+		case q == 27:
+			return ','
+		}
+		return letterBytes[randomAt(key, pos, len(letterBytes))]
+	},
+	seal: func(tail []byte, n uint) {
+		if n < 2 {
+			// the only valid one byte document is a number
+			for i := range tail {
+				tail[i] = '0'
+			}
+			return
+		}
+		// drop the member cut by payload end and the comma before it
+		start := uint(1)
+		if members := (n - 2) / jsonMemberSize; members > 0 {
+			start = members * jsonMemberSize
+		}
+		offset := n - uint(len(tail))
+		for pos := start; pos < n-1; pos++ {
+			tail[pos-offset] = ' '
+		}
+		tail[len(tail)-1] = '}'
+	},
+	tailSize: jsonMemberSize + 1,
+	prefix:   1,
+	period:   jsonMemberSize,
+}
+
+const jsonMemberSize = 28
@@ -0,0 +1,199 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"unicode/utf8"
+)
+
+const maxTestedSize = 400
+
+// validators of modes which payload must be valid as a whole at any size
+var modeValidators = map[Mode]func([]byte) bool{
+	ModeUTF8: utf8.Valid,
+	ModeJSON: func(b []byte) bool { return len(b) == 0 || json.Valid(b) },
+}
+
+func TestContentModeValid(t *testing.T) {
+	for mode, valid := range modeValidators {
+		content := NewStrategyContent(0, &LinearStrategy{Step: 1}, mode, NewSeeder(1).Rand())
+		for n := uint(0); n <= maxTestedSize; n++ {
+			payload, err := content.SetSize(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint(len(payload)) != n {
+				t.Fatalf("%s: SetSize(%d) returned %d bytes", mode, n, len(payload))
+			}
+			if !valid(payload) {
+				t.Errorf("%s: payload of %d bytes is invalid: %q", mode, n, payload)
+			}
+		}
+	}
+}
+
+func TestContentModeValidAfterShrink(t *testing.T) {
+	for mode, valid := range modeValidators {
+		content := NewStrategyContent(0, &LinearStrategy{Step: 1}, mode, NewSeeder(1).Rand())
+		// every size is reached both growing from a smaller one and shrinking from a larger one
+		for n := uint(1); n <= maxTestedSize; n++ {
+			for _, size := range []uint{n + 7, n} {
+				payload, err := content.SetSize(size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !valid(payload) {
+					t.Errorf("%s: payload of %d bytes is invalid after %d bytes: %q", mode, size, n+7, payload)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamContentModeValid(t *testing.T) {
+	sizes := []uint{streamBlockSize - 1, streamBlockSize, streamBlockSize + 1, 3*streamBlockSize + 17}
+	for n := uint(0); n <= maxTestedSize; n++ {
+		sizes = append(sizes, n)
+	}
+	for mode, valid := range modeValidators {
+		content := NewStreamContent(0, &LinearStrategy{Step: 1}, mode, NewSeeder(1).Rand())
+		for _, n := range sizes {
+			content.SetSize(n)
+			r, length := content.Reader()
+			payload, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint(len(payload)) != n || length != len(payload) {
+				t.Fatalf("%s: reader of %d bytes produced %d bytes, reported %d", mode, n, len(payload), length)
+			}
+			if !valid(payload) {
+				t.Errorf("%s: streamed payload of %d bytes is invalid", mode, n)
+			}
+		}
+	}
+}
+
+func TestStreamContentMatchesContent(t *testing.T) {
+	// bytes depend only on position and key drawn from the same source
+	for _, mode := range Modes {
+		content := NewStrategyContent(0, &LinearStrategy{Step: 1}, mode, NewSeeder(1).Rand())
+		stream := NewStreamContent(0, &LinearStrategy{Step: 1}, mode, NewSeeder(1).Rand())
+		for n := uint(0); n <= maxTestedSize; n++ {
+			payload, _ := content.SetSize(n)
+			stream.SetSize(n)
+			r, _ := stream.Reader()
+			streamed, _ := ioutil.ReadAll(r)
+			if !bytes.Equal(payload, streamed) {
+				t.Errorf("%s: streamed payload of %d bytes differs from generated one", mode, n)
+			}
+		}
+	}
+}
+
+func TestContentDoesNotDependOnHistory(t *testing.T) {
+	for _, mode := range Modes {
+		content := NewStrategyContent(0, &LinearStrategy{Step: 1}, mode, NewSeeder(1).Rand())
+		// sizes requested while bisecting
+		for _, n := range []uint{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 384, 320, 352, 336} {
+			content.SetSize(n)
+		}
+		replayed, _ := NewStrategyContent(0, nil, mode, NewSeeder(1).Rand()).SetSize(336)
+		if payload, _ := content.SetSize(336); !bytes.Equal(payload, replayed) {
+			t.Errorf("%s: payload differs from the one replayed from seed", mode)
+		}
+	}
+}
+
+func TestItemsDiffer(t *testing.T) {
+	it := &items{prefix: "X-Fatty-", valueSize: 8, mode: ModeAlnum, key: NewSeeder(1).Rand().Uint64()}
+	_, values := it.get(2)
+	if bytes.Equal(values[0], values[1]) {
+		t.Errorf("values of different items are the same: %q", values[0])
+	}
+}
+
+func TestHeaderModesHaveNoControlBytes(t *testing.T) {
+	for _, mode := range Modes {
+		if headerUnsafeModes[mode] {
+			if _, err := ParseHeaderModes(string(mode)); err == nil {
+				t.Errorf("ParseHeaderModes(%q): expected error", mode)
+			}
+			continue
+		}
+		if _, err := ParseHeaderModes(string(mode)); err != nil {
+			t.Errorf("ParseHeaderModes(%q): unexpected error: %s", mode, err)
+		}
+		payload, _ := NewStrategyContent(0, nil, mode, NewSeeder(1).Rand()).SetSize(maxTestedSize)
+		for i, b := range payload {
+			if b < 0x20 || b == 0x7F {
+				t.Errorf("%s: control byte 0x%02x at %d", mode, b, i)
+				break
+			}
+		}
+	}
+}
+
+// cookie-octet as defined by RFC 6265
+func isCookieOctet(b byte) bool {
+	return b == 0x21 || b >= 0x23 && b <= 0x2B || b >= 0x2D && b <= 0x3A || b >= 0x3C && b <= 0x5B || b >= 0x5D && b <= 0x7E
+}
+
+func TestCookieModesHaveOnlyCookieOctets(t *testing.T) {
+	for _, mode := range Modes {
+		if !cookieSafeModes[mode] {
+			if _, err := ParseCookieModes(string(mode)); err == nil {
+				t.Errorf("ParseCookieModes(%q): expected error", mode)
+			}
+			continue
+		}
+		if _, err := ParseCookieModes(string(mode)); err != nil {
+			t.Errorf("ParseCookieModes(%q): unexpected error: %s", mode, err)
+		}
+		payload, _ := NewStrategyContent(0, nil, mode, NewSeeder(1).Rand()).SetSize(4 * maxTestedSize)
+		for i, b := range payload {
+			if !isCookieOctet(b) {
+				t.Errorf("%s: byte 0x%02x at %d is not a cookie-octet", mode, b, i)
+				break
+			}
+		}
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []Mode
+		wantErr bool
+	}{
+		{s: "", want: []Mode{ModeAlnum}},
+		{s: "utf8, json", want: []Mode{ModeUTF8, ModeJSON}},
+		{s: "zeros,", want: []Mode{ModeZeros}},
+		{s: "ascii", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseModes(tt.s)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseModes(%q): expected error", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseModes(%q): unexpected error: %s", tt.s, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseModes(%q) = %v, want %v", tt.s, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseModes(%q) = %v, want %v", tt.s, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/valyala/fasthttp"
+)
+
+// boundary of generated multipart/form-data bodies, it can't appear in alnum payload
+const MultipartBoundary = "----fattyFormBoundary"
+
+// prefix of part names set by multipart probes
+const MultipartPrefix = "fatty"
+
+const multipartContentType = "multipart/form-data; boundary=" + MultipartBoundary
+
+// multipartFieldHeader returns everything preceding value of a form field
+func multipartFieldHeader(name string) string {
+	return fmt.Sprintf("--%s\r\nContent-Disposition: form-data; name=%q\r\n\r\n", MultipartBoundary, name)
+}
+
+// multipartFileHeader returns everything preceding content of a file part
+func multipartFileHeader(name string) string {
+	return fmt.Sprintf("--%s\r\nContent-Disposition: form-data; name=%q; filename=%q\r\nContent-Type: application/octet-stream\r\n\r\n",
+		MultipartBoundary, name, name+".bin")
+}
+
+const multipartPartEnd = "\r\n"
+
+const multipartEnd = "--" + MultipartBoundary + "--\r\n"
+
+// MultipartPartsProbe grows number of small form fields in multipart/form-data body
+type MultipartPartsProbe struct {
+	counter *Counter
+	items   *items
+	// body size by parts count
+	bodyBytes map[int]int
+}
+
+func NewMultipartPartsProbe(counter *Counter, valueSize uint, rnd *rand.Rand) *MultipartPartsProbe {
+	return &MultipartPartsProbe{
+		counter:   counter,
+		items:     &items{prefix: MultipartPrefix, valueSize: valueSize, mode: ModeAlnum, key: rnd.Uint64()},
+		bodyBytes: make(map[int]int),
+	}
+}
+
+func (p *MultipartPartsProbe) Name() string {
+	return "multipart parts"
+}
+
+func (p *MultipartPartsProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *MultipartPartsProbe) Prepare(req *fasthttp.Request, size int) error {
+	names, values := p.items.get(size)
+	var body bytes.Buffer
+	for i := range names {
+		body.WriteString(multipartFieldHeader(names[i]))
+		body.Write(values[i])
+		body.WriteString(multipartPartEnd)
+	}
+	body.WriteString(multipartEnd)
+	req.Header.SetContentType(multipartContentType)
+	p.bodyBytes[size] = body.Len()
+	req.SetBodyStream(bytes.NewReader(body.Bytes()), body.Len())
+	return nil
+}
+
+// Check compares parts sent with the ones received by fatty server
+func (p *MultipartPartsProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	echo := parseEcho(resp)
+	if echo == nil {
+		return VerdictOK
+	}
+	names, values := p.items.get(size)
+	for i := range names {
+		if length, ok := echo.Parts[names[i]]; !ok || length != len(values[i]) {
+			return VerdictDropped
+		}
+	}
+	return VerdictOK
+}
+
+func (p *MultipartPartsProbe) Report(result *ProbeResult) {
+	result.Unit = "parts"
+	if bytes, ok := p.bodyBytes[result.Accepted]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("body at max count: %d bytes", bytes))
+	}
+}
+
+var (
+	_ Probe          = (*MultipartPartsProbe)(nil)
+	_ CheckingProbe  = (*MultipartPartsProbe)(nil)
+	_ ReportingProbe = (*MultipartPartsProbe)(nil)
+)
+
+// MultipartFileProbe grows file parts of multipart/form-data body, either size
+// of a single file or the whole body size spread evenly among several files
+type MultipartFileProbe struct {
+	content StreamableContent
+	files   int
+	// probed size is the whole body size rather than size of the file
+	total bool
+	// file sizes by probed size
+	sizes map[int][]int
+}
+
+// NewMultipartFileProbe grows size of a single file part
+func NewMultipartFileProbe(content StreamableContent) *MultipartFileProbe {
+	return &MultipartFileProbe{content: content, files: 1, sizes: make(map[int][]int)}
+}
+
+// NewMultipartTotalProbe grows size of the whole body consisting of given number of file parts
+func NewMultipartTotalProbe(content StreamableContent, files uint) *MultipartFileProbe {
+	return &MultipartFileProbe{content: content, files: int(files), total: true, sizes: make(map[int][]int)}
+}
+
+func (p *MultipartFileProbe) Name() string {
+	if p.total {
+		return fmt.Sprintf("multipart total (%d files)", p.files)
+	}
+	return "multipart file"
+}
+
+// Grow returns size of the file or, in total mode, size of the whole body
+// with content grown as size of all files together
+func (p *MultipartFileProbe) Grow() (int, error) {
+	size, err := p.content.Grow()
+	return int(size) + p.MinSize(), err
+}
+
+// MinSize returns size of the body with empty files in total mode, smaller body can't hold them
+func (p *MultipartFileProbe) MinSize() int {
+	if !p.total {
+		return 0
+	}
+	overhead := len(multipartEnd)
+	for i := 0; i < p.files; i++ {
+		overhead += len(multipartFileHeader(fmt.Sprintf("%s%d", MultipartPrefix, i))) + len(multipartPartEnd)
+	}
+	return overhead
+}
+
+func (p *MultipartFileProbe) Prepare(req *fasthttp.Request, size int) error {
+	names := make([]string, p.files)
+	overhead := len(multipartEnd)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", MultipartPrefix, i)
+		overhead += len(multipartFileHeader(names[i])) + len(multipartPartEnd)
+	}
+
+	sizes := make([]int, p.files)
+	if p.total {
+		if size < overhead {
+			return errors.New(fmt.Sprintf("Multipart body of %d bytes can't hold %d files", size, p.files))
+		}
+		for i := range sizes {
+			sizes[i] = (size - overhead) / p.files
+		}
+		sizes[len(sizes)-1] += (size - overhead) % p.files
+	} else {
+		sizes[0] = size
+	}
+
+	var readers []io.Reader
+	length := 0
+	for i, name := range names {
+		if _, err := p.content.SetSize(uint(sizes[i])); err != nil {
+			return err
+		}
+		file, n := p.content.Reader()
+		readers = append(readers, bytes.NewReader([]byte(multipartFileHeader(name))), file, bytes.NewReader([]byte(multipartPartEnd)))
+		length += n
+	}
+	readers = append(readers, bytes.NewReader([]byte(multipartEnd)))
+	// readers keep their own size, content goes on growing from the probed one
+	if _, err := p.content.SetSize(uint(size - p.MinSize())); err != nil {
+		return err
+	}
+
+	req.Header.SetContentType(multipartContentType)
+	req.SetBodyStream(io.MultiReader(readers...), length+overhead)
+	p.sizes[size] = sizes
+	return nil
+}
+
+// Check makes sure fatty server received every file in full
+func (p *MultipartFileProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	echo := parseEcho(resp)
+	if echo == nil {
+		return VerdictOK
+	}
+	for i, n := range p.sizes[size] {
+		if length, ok := echo.Parts[fmt.Sprintf("%s%d", MultipartPrefix, i)]; !ok || length != n {
+			return VerdictDropped
+		}
+	}
+	return VerdictOK
+}
+
+func (p *MultipartFileProbe) Report(result *ProbeResult) {
+	if p.total && result.Accepted < 0 && result.Rejected == p.MinSize() {
+		result.Details = append(result.Details, fmt.Sprintf("limit is below %d bytes taken by %d empty files", p.MinSize(), p.files))
+	}
+	if sizes, ok := p.sizes[result.Accepted]; ok && p.total {
+		result.Details = append(result.Details, fmt.Sprintf("file parts at max size: %d bytes each", sizes[0]))
+	}
+}
+
+func (p *MultipartFileProbe) Mode() Mode {
+	if m, ok := p.content.(ModalContent); ok {
+		return m.Mode()
+	}
+	return ""
+}
+
+var (
+	_ Probe          = (*MultipartFileProbe)(nil)
+	_ BoundedProbe   = (*MultipartFileProbe)(nil)
+	_ CheckingProbe  = (*MultipartFileProbe)(nil)
+	_ ReportingProbe = (*MultipartFileProbe)(nil)
+)
@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// PoolConn is a connection several requests are multiplexed over
+type PoolConn interface {
+	http.RoundTripper
+	// Closed tells whether connection can't take new requests anymore
+	Closed() bool
+}
+
+// Pool is a fixed number of connections shared by load emitters,
+// every connection carries up to a fixed number of concurrent requests
+type Pool struct {
+	dial  func() (PoolConn, error)
+	mu    sync.Mutex
+	conns []PoolConn
+	// index of connection for every free stream
+	slots chan int
+}
+
+func NewPool(conns, streams int, dial func() (PoolConn, error)) *Pool {
+	pool := &Pool{
+		dial:  dial,
+		conns: make([]PoolConn, conns),
+		slots: make(chan int, conns*streams),
+	}
+	// streams are spread over connections evenly
+	for s := 0; s < streams; s++ {
+		for c := 0; c < conns; c++ {
+			pool.slots <- c
+		}
+	}
+	return pool
+}
+
+// Do waits for a free stream, sends request over it and reads the whole response body,
+// it returns response status code and body length
+func (p *Pool) Do(req *http.Request) (int, int, error) {
+	i := <-p.slots
+	defer func() {
+		p.slots <- i
+	}()
+
+	conn, err := p.conn(i)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := conn.RoundTrip(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, int(n), err
+}
+
+// conn returns i-th connection, it's reopened if the server has closed it
+func (p *Pool) conn(i int) (PoolConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if conn := p.conns[i]; conn != nil && !conn.Closed() {
+		return conn, nil
+	}
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	p.conns[i] = conn
+	return conn, nil
+}
+
+// Multiplexing load testing emitter, requests of all workers are sent over connections of the pool
+
+type MultiplexLoadEmitter struct {
+	pool  *Pool
+	proxy *url.URL
+
+	options *LoadEmitterOptions
+}
+
+func NewMultiplexLoadEmitter(options *LoadEmitterOptions, pool *Pool, proxy *url.URL) Emitter {
+	emitter := &MultiplexLoadEmitter{}
+	emitter.options = options
+	emitter.pool = pool
+	emitter.proxy = proxy
+	return emitter
+}
+
+func (e *MultiplexLoadEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
+
+	for {
+		select {
+		case u := <-e.options.Urls:
+
+			req, err := http.NewRequest(http.MethodGet, u, nil)
+			if err != nil {
+				log <- &ClassifiedError{Verdict: VerdictError, Err: err}
+				continue
+			}
+			if e.proxy != nil && e.proxy.User != nil {
+				req.Header.Set("Proxy-Authorization", proxyAuthorization(e.proxy))
+			}
+
+			start := time.Now()
+			code, length, err := e.pool.Do(req)
+			if err != nil {
+				log <- &ClassifiedError{Verdict: classifyError(err), Err: err}
+			} else {
+				log <- LoadEmitterEvent{
+					Code:          code,
+					RequestTime:   time.Since(start),
+					RequestLength: length,
+					Verdict:       classifyCode(code),
+				}
+			}
+
+			select {
+			case _, ok := <-stop:
+				if !ok {
+					// channel closed so exit
+					done <- struct{}{}
+					return
+				}
+			default:
+			}
+		default:
+			// send message that this emitter has done all work
+			done <- struct{}{}
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// header pipelined requests are numbered with, fatty server echoes it back
+const PipelineSeqHeader = "X-Fatty-Seq"
+
+// PipelineProbe grows number of requests sent over a single connection
+// before reading any response
+type PipelineProbe struct {
+	counter *Counter
+
+	// depth of the request being sent
+	depth int
+	// outcome of pipeline by depth
+	received map[int]int
+	ordered  map[int]bool
+	echoed   bool
+}
+
+func NewPipelineProbe(counter *Counter) *PipelineProbe {
+	return &PipelineProbe{counter: counter, received: make(map[int]int), ordered: make(map[int]bool)}
+}
+
+func (p *PipelineProbe) Name() string {
+	return "pipeline depth"
+}
+
+func (p *PipelineProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *PipelineProbe) Prepare(req *fasthttp.Request, size int) error {
+	p.depth = size
+	return nil
+}
+
+func (p *PipelineProbe) Client(dest, proxy *url.URL) Client {
+	return &pipelineClient{raw: NewRawClient(dest, proxy), probe: p}
+}
+
+// Check reports responses which came out of order
+func (p *PipelineProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	if !p.ordered[size] {
+		return VerdictReordered
+	}
+	return VerdictOK
+}
+
+func (p *PipelineProbe) Report(result *ProbeResult) {
+	result.Unit = "requests"
+	if received, ok := p.received[result.Rejected]; ok && received < result.Rejected {
+		result.Details = append(result.Details, fmt.Sprintf("responses read at rejected depth: %d of %d", received, result.Rejected))
+	}
+	if !p.echoed && result.Accepted > 0 {
+		result.Details = append(result.Details, fmt.Sprintf("server doesn't echo %s, order of responses isn't checked", PipelineSeqHeader))
+	}
+}
+
+var (
+	_ Probe          = (*PipelineProbe)(nil)
+	_ ClientProbe    = (*PipelineProbe)(nil)
+	_ CheckingProbe  = (*PipelineProbe)(nil)
+	_ ReportingProbe = (*PipelineProbe)(nil)
+)
+
+// pipelineClient writes request as many times as probe's depth is, then reads the responses
+type pipelineClient struct {
+	raw   *RawClient
+	probe *PipelineProbe
+}
+
+// DoTimeout returns the first failed response, or the last one if all of them are successful
+func (c *pipelineClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	depth := c.probe.depth
+	conn, err := c.raw.Dial(timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	// responses are read while requests are written, server may stop reading
+	// until it can write responses
+	read := make(chan error, 1)
+	go func() {
+		read <- c.read(conn, resp, depth)
+	}()
+
+	for i := 0; i < depth; i++ {
+		req.Header.Set(PipelineSeqHeader, strconv.Itoa(i))
+		if err = conn.WriteRequest(req); err != nil {
+			conn.Close()
+			<-read
+			return err
+		}
+	}
+	return <-read
+}
+
+func (c *pipelineClient) read(conn *RawConn, resp *fasthttp.Response, depth int) error {
+	c.probe.ordered[depth] = true
+	for i := 0; i < depth; i++ {
+		c.probe.received[depth] = i
+		if err := conn.ReadResponse(resp); err != nil {
+			return err
+		}
+		if seq := resp.Header.Peek(PipelineSeqHeader); len(seq) > 0 {
+			c.probe.echoed = true
+			if string(seq) != strconv.Itoa(i) {
+				c.probe.ordered[depth] = false
+			}
+		}
+		if resp.StatusCode() >= 400 {
+			// the rest of responses is not read
+			c.probe.received[depth] = i + 1
+			return nil
+		}
+	}
+	c.probe.received[depth] = depth
+	return nil
+}
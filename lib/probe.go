@@ -0,0 +1,765 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Probe is a single request dimension which limit is being searched for
+type Probe interface {
+	// Name returns human readable name of probed dimension
+	Name() string
+	// Grow increases probed value and returns its new size
+	Grow() (int, error)
+	// Prepare puts probed value of exact size into request
+	Prepare(req *fasthttp.Request, size int) error
+}
+
+// ReportingProbe adds probe specific details to it's result
+type ReportingProbe interface {
+	Report(result *ProbeResult)
+}
+
+// CheckingProbe inspects successful responses, e.g. for silently dropped request parts
+type CheckingProbe interface {
+	Check(resp *fasthttp.Response, size int) Verdict
+}
+
+// BoundedProbe can't put values smaller than MinSize into request,
+// e.g. multipart body can't be smaller than it's part headers
+type BoundedProbe interface {
+	MinSize() int
+}
+
+// ChunkedProbe sends body in chunks of fixed size, fasthttp can't write chunks bigger
+// than it's read buffer, so such requests are written by RawClient
+type ChunkedProbe interface {
+	ChunkSize() int
+}
+
+// SlowProbe spends given time on sending request of given size, such requests are
+// written by RawClient which sends every piece of body as soon as it's ready
+type SlowProbe interface {
+	Duration(size int) time.Duration
+}
+
+// PacedProbe spreads header lines of request of given size over given time,
+// such requests are written by RawClient
+type PacedProbe interface {
+	HeaderDuration(size int) time.Duration
+}
+
+// ClientProbe sends requests with it's own client, e.g. several requests at once
+type ClientProbe interface {
+	Client(dest, proxy *url.URL) Client
+}
+
+// HeaderProbe grows value of a single request header
+type HeaderProbe struct {
+	name    string
+	prefix  string
+	content GrowableContent
+}
+
+// headerValuePrefixes make probed values look like the real ones, otherwise
+// servers may reject them for malformed value instead of the size
+var headerValuePrefixes = map[string]string{
+	"Authorization": "Bearer ",
+	"Cookie":        "fatty=",
+}
+
+// NewHeaderProbe creates probe of the header value size, size includes value prefix
+// for headers which have one, e.g. "Bearer " for Authorization
+func NewHeaderProbe(name string, content GrowableContent) *HeaderProbe {
+	name = http.CanonicalHeaderKey(name)
+	return &HeaderProbe{name: name, prefix: headerValuePrefixes[name], content: content}
+}
+
+func (p *HeaderProbe) Name() string {
+	return "header " + p.name
+}
+
+func (p *HeaderProbe) Grow() (int, error) {
+	payload, err := p.content.Grow()
+	return len(p.prefix) + len(payload), err
+}
+
+func (p *HeaderProbe) Prepare(req *fasthttp.Request, size int) error {
+	if size < len(p.prefix) {
+		req.Header.Set(p.name, p.prefix[:size])
+		return nil
+	}
+	payload, err := p.content.SetSize(uint(size - len(p.prefix)))
+	if err != nil {
+		return err
+	}
+	req.Header.SetBytesV(p.name, append([]byte(p.prefix), payload...))
+	return nil
+}
+
+func (p *HeaderProbe) Mode() Mode {
+	if m, ok := p.content.(ModalContent); ok {
+		return m.Mode()
+	}
+	return ""
+}
+
+var _ Probe = (*HeaderProbe)(nil)
+
+// BodyProbe grows request body
+type BodyProbe struct {
+	content GrowableContent
+}
+
+func NewBodyProbe(content GrowableContent) *BodyProbe {
+	return &BodyProbe{content: content}
+}
+
+func (p *BodyProbe) Name() string {
+	return "body"
+}
+
+func (p *BodyProbe) Grow() (int, error) {
+	payload, err := p.content.Grow()
+	return len(payload), err
+}
+
+func (p *BodyProbe) Prepare(req *fasthttp.Request, size int) error {
+	payload, err := p.content.SetSize(uint(size))
+	if err != nil {
+		return err
+	}
+	// stream the payload to avoid copying it into request buffer
+	req.SetBodyStream(bytes.NewReader(payload), len(payload))
+	return nil
+}
+
+func (p *BodyProbe) Mode() Mode {
+	if m, ok := p.content.(ModalContent); ok {
+		return m.Mode()
+	}
+	return ""
+}
+
+var _ Probe = (*BodyProbe)(nil)
+
+// StreamBodyProbe grows request body which is generated while it's being sent
+type StreamBodyProbe struct {
+	content StreamableContent
+	// size of chunks body is sent in, 0 means body is sent with content length
+	chunkSize int
+}
+
+func NewStreamBodyProbe(content StreamableContent, chunkSize int) *StreamBodyProbe {
+	return &StreamBodyProbe{content: content, chunkSize: chunkSize}
+}
+
+func (p *StreamBodyProbe) Name() string {
+	if p.chunkSize > 0 {
+		return fmt.Sprintf("body (chunked by %d)", p.chunkSize)
+	}
+	return "body (stream)"
+}
+
+func (p *StreamBodyProbe) Grow() (int, error) {
+	size, err := p.content.Grow()
+	return int(size), err
+}
+
+func (p *StreamBodyProbe) Prepare(req *fasthttp.Request, size int) error {
+	if _, err := p.content.SetSize(uint(size)); err != nil {
+		return err
+	}
+	body, length := p.content.Reader()
+	if p.chunkSize > 0 {
+		// negative size means chunked transfer encoding
+		length = -1
+	}
+	req.SetBodyStream(body, length)
+	return nil
+}
+
+func (p *StreamBodyProbe) ChunkSize() int {
+	return p.chunkSize
+}
+
+func (p *StreamBodyProbe) Report(result *ProbeResult) {
+	if p.chunkSize > 0 && result.Accepted > 0 {
+		chunks := (result.Accepted + p.chunkSize - 1) / p.chunkSize
+		result.Details = append(result.Details, fmt.Sprintf("chunks at max size: %d", chunks))
+	}
+}
+
+func (p *StreamBodyProbe) Mode() Mode {
+	if m, ok := p.content.(ModalContent); ok {
+		return m.Mode()
+	}
+	return ""
+}
+
+var (
+	_ Probe          = (*StreamBodyProbe)(nil)
+	_ ChunkedProbe   = (*StreamBodyProbe)(nil)
+	_ ReportingProbe = (*StreamBodyProbe)(nil)
+)
+
+// Probe emitter grows probed value until the server rejects it,
+// then bisects between the last accepted and the first rejected sizes.
+
+type ProbeEmitter struct {
+	client     Client
+	proxy      *url.URL
+	probe      Probe
+	classifier *Classifier
+
+	options *ProbeEmitterOptions
+}
+
+type ProbeEmitterOptions struct {
+	Dest   *url.URL
+	Method string
+	// Max number of requests to perform, 0 = limitless
+	Limit uint32
+	// Max size to grow probed value to, 0 = limitless
+	MaxSize        int
+	RequestTimeout time.Duration
+	// Decides whether response is accepted, NewClassifier() is used if nil
+	Classifier *Classifier
+	// Http version requests are sent with, http/1.1 if empty
+	Proto Protocol
+}
+
+// classifier returns classifier set in options or the default one
+func (o *ProbeEmitterOptions) classifier() *Classifier {
+	if o.Classifier != nil {
+		return o.Classifier
+	}
+	return NewClassifier()
+}
+
+// interrupted checks whether emitter was stopped or has run out of requests
+func (o *ProbeEmitterOptions) interrupted(stop chan struct{}, requests int) bool {
+	if o.Limit > 0 && uint32(requests) >= o.Limit {
+		return true
+	}
+	select {
+	case _, ok := <-stop:
+		if !ok {
+			return true
+		}
+	default:
+	}
+	return false
+}
+
+// ProbeResult is sent by probe emitter when the limit search is over
+type ProbeResult struct {
+	Name string
+	// Proxy requests were sent through, empty if they were sent directly
+	Via string
+	// Http version requests were sent with, empty for http/1.1
+	Proto Protocol
+	// Mode probed payload was generated in, empty if it's not generated
+	Mode Mode
+	// Largest accepted size, -1 if nothing was accepted
+	Accepted int
+	// Smallest rejected size, -1 if nothing was rejected
+	Rejected int
+	// Status code of the smallest rejected request, 0 on connection error
+	Code int
+	// Why the smallest rejected request was counted as rejected
+	Verdict  Verdict
+	Requests int
+	// Search was interrupted before the exact limit was found
+	Interrupted bool
+	// Error which stopped the search, nil if it has run to the end
+	Err error
+	// Largest accepted size was accepted once again after the search
+	Confirmed bool
+	// What sizes are measured in, bytes if empty
+	Unit string
+	// Probe specific notes about the found limit
+	Details []string
+	// Largest size accepted with nothing dropped and smallest size accepted
+	// with some parts silently dropped, -1 if there were no such sizes
+	Intact, Dropped int
+}
+
+// newProbeResult returns result with nothing accepted or rejected yet
+func newProbeResult(name string, proxy *url.URL) ProbeResult {
+	result := ProbeResult{Name: name, Accepted: -1, Rejected: -1, Intact: -1, Dropped: -1}
+	if proxy != nil {
+		result.Via = proxy.Host
+	}
+	return result
+}
+
+func (r ProbeResult) Print() {
+	name := r.Name
+	if r.Mode != "" {
+		name += fmt.Sprintf(" [%s]", r.Mode)
+	}
+	if r.Proto != "" {
+		name += " over " + string(r.Proto)
+	}
+	if r.Via != "" {
+		name += " via " + r.Via
+	}
+	fmt.Printf("Probe %s (%d requests):\n", name, r.Requests)
+	unit := r.Unit
+	if unit == "" {
+		unit = "bytes"
+	}
+	switch {
+	case r.Err != nil:
+		fmt.Printf("  search failed: %s\n", r.Err)
+		if r.Accepted >= 0 {
+			fmt.Printf("  largest accepted before the failure: %d %s\n", r.Accepted, unit)
+		}
+	case r.Accepted < 0 && r.Rejected < 0:
+		fmt.Println("  no requests were made")
+	case r.Rejected < 0:
+		fmt.Printf("  no limit found, largest accepted: %d %s\n", r.Accepted, unit)
+	case r.Accepted < 0:
+		fmt.Printf("  every request was rejected, smallest rejected: %d %s (%s, code %d)\n", r.Rejected, unit, r.Verdict, r.Code)
+	case r.Interrupted:
+		fmt.Printf("  limit is between %d and %d %s (%s, code %d)\n", r.Accepted, r.Rejected, unit, r.Verdict, r.Code)
+	case !r.Confirmed:
+		fmt.Printf("  limit is flaky, %d %s accepted once, rejected on retry (%s, code %d)\n", r.Rejected, unit, r.Verdict, r.Code)
+	default:
+		fmt.Printf("  max accepted: %d %s, rejected from %d %s (%s, code %d)\n", r.Accepted, unit, r.Rejected, unit, r.Verdict, r.Code)
+	}
+	if r.Dropped >= 0 {
+		fmt.Printf("  silently dropped from %d %s, intact up to %d %s\n", r.Dropped, unit, r.Intact, unit)
+	}
+	for _, detail := range r.Details {
+		fmt.Printf("  %s\n", detail)
+	}
+}
+
+// Limit returns short description of the found limit
+func (r ProbeResult) Limit() string {
+	switch {
+	case r.Err != nil:
+		return "failed"
+	case r.Accepted < 0 && r.Rejected < 0:
+		return "-"
+	case r.Rejected < 0:
+		return fmt.Sprintf(">=%d", r.Accepted)
+	case r.Accepted < 0:
+		return fmt.Sprintf("<%d", r.Rejected)
+	case r.Interrupted:
+		return fmt.Sprintf("%d..%d", r.Accepted, r.Rejected)
+	case !r.Confirmed:
+		return fmt.Sprintf("~%d", r.Rejected)
+	}
+	return fmt.Sprintf("%d", r.Accepted)
+}
+
+// PrintResultsTable prints found limits of all probes side by side
+func PrintResultsTable(results []ProbeResult) {
+	if len(results) < 2 {
+		return
+	}
+	sorted := append([]ProbeResult(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		if sorted[i].Via != sorted[j].Via {
+			return sorted[i].Via < sorted[j].Via
+		}
+		if sorted[i].Proto != sorted[j].Proto {
+			return sorted[i].Proto < sorted[j].Proto
+		}
+		return sorted[i].Mode < sorted[j].Mode
+	})
+
+	fmt.Println("Limits:")
+	fmt.Printf("%-32s %-21s %-8s %-14s %16s %s\n", "probe", "via", "proto", "mode", "max accepted", "rejected as")
+	for _, r := range sorted {
+		via := r.Via
+		if via == "" {
+			via = "direct"
+		}
+		proto := r.Proto
+		if proto == "" {
+			proto = HTTP11
+		}
+		verdict := "-"
+		if r.Rejected >= 0 {
+			verdict = r.Verdict.String()
+		}
+		fmt.Printf("%-32s %-21s %-8s %-14s %16s %s\n", r.Name, via, proto, r.Mode, r.Limit(), verdict)
+	}
+}
+
+// PrintProtocolMatrix prints max accepted sizes of probes run over several http versions
+func PrintProtocolMatrix(results []ProbeResult) {
+	var names []string
+	var protos []Protocol
+	cells := make(map[string]map[Protocol]ProbeResult)
+	for _, r := range results {
+		proto := r.Proto
+		if proto == "" {
+			proto = HTTP11
+		}
+		if _, ok := cells[r.Name]; !ok {
+			names = append(names, r.Name)
+			cells[r.Name] = make(map[Protocol]ProbeResult)
+		}
+		cells[r.Name][proto] = r
+	}
+	sort.Strings(names)
+	for _, p := range Protocols {
+		for _, name := range names {
+			if _, ok := cells[name][p]; ok {
+				protos = append(protos, p)
+				break
+			}
+		}
+	}
+	if len(protos) < 2 {
+		return
+	}
+
+	fmt.Println("Max accepted size by protocol:")
+	fmt.Printf("%-32s", "")
+	for _, p := range protos {
+		fmt.Printf(" %16s", p)
+	}
+	fmt.Println()
+	for _, name := range names {
+		if len(cells[name]) < 2 {
+			continue
+		}
+		fmt.Printf("%-32s", name)
+		for _, p := range protos {
+			if r, ok := cells[name][p]; ok {
+				fmt.Printf(" %16s", r.Limit())
+			} else {
+				fmt.Printf(" %16s", "")
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// PrintModeMatrix prints max accepted sizes of probes run in several modes
+func PrintModeMatrix(results []ProbeResult) {
+	var names []string
+	var modes []Mode
+	cells := make(map[string]map[Mode]ProbeResult)
+	for _, r := range results {
+		// protocols are compared separately
+		if r.Mode == "" || r.Proto != "" {
+			continue
+		}
+		if _, ok := cells[r.Name]; !ok {
+			names = append(names, r.Name)
+			cells[r.Name] = make(map[Mode]ProbeResult)
+		}
+		cells[r.Name][r.Mode] = r
+	}
+	sort.Strings(names)
+	for _, m := range Modes {
+		for _, name := range names {
+			if _, ok := cells[name][m]; ok {
+				modes = append(modes, m)
+				break
+			}
+		}
+	}
+	if len(modes) < 2 {
+		return
+	}
+
+	fmt.Println("Max accepted size by mode:")
+	fmt.Printf("%-24s", "")
+	for _, m := range modes {
+		fmt.Printf(" %16s", m)
+	}
+	fmt.Println()
+	for _, name := range names {
+		fmt.Printf("%-24s", name)
+		for _, m := range modes {
+			if r, ok := cells[name][m]; ok {
+				fmt.Printf(" %16s", r.Limit())
+			} else {
+				fmt.Printf(" %16s", "")
+			}
+		}
+		fmt.Println()
+	}
+}
+
+func NewProbeEmitter(options *ProbeEmitterOptions, probe Probe, proxy *url.URL) Emitter {
+	emitter := &ProbeEmitter{}
+	emitter.options = options
+	emitter.probe = probe
+	emitter.proxy = proxy
+	if options.Proto == H2 || options.Proto == H2C {
+		emitter.client = NewH2Client(options.Dest, proxy, options.Proto)
+	} else if options.Proto == H3 {
+		emitter.client = NewH3Client(options.Dest, proxy)
+	} else if p, ok := probe.(ClientProbe); ok {
+		emitter.client = p.Client(options.Dest, proxy)
+	} else if p, ok := probe.(ChunkedProbe); ok && p.ChunkSize() > 0 {
+		client := NewRawClient(options.Dest, proxy)
+		client.ChunkSize = p.ChunkSize()
+		emitter.client = client
+	} else if _, ok := probe.(SlowProbe); ok {
+		emitter.client = NewRawClient(options.Dest, proxy)
+	} else if _, ok := probe.(PacedProbe); ok {
+		emitter.client = NewRawClient(options.Dest, proxy)
+	} else {
+		emitter.client = newHostClient(options.Dest, proxy)
+	}
+	emitter.classifier = options.classifier()
+	return emitter
+}
+
+func (e *ProbeEmitter) Start(stop, done chan struct{}, log chan EmitterEvent) {
+
+	result := newProbeResult(e.probe.Name(), e.proxy)
+	if m, ok := e.probe.(ModalContent); ok {
+		result.Mode = m.Mode()
+	}
+	if e.options.Proto != HTTP11 {
+		result.Proto = e.options.Proto
+	}
+
+	defer func() {
+		if p, ok := e.probe.(ReportingProbe); ok {
+			p.Report(&result)
+		}
+		if c, ok := e.client.(*H2Client); ok {
+			if _, ok := e.probe.(*HeaderProbe); ok {
+				result.Details = append(result.Details, c.HeaderListDetail(e.headerListSize(result.Accepted), e.headerListSize(result.Rejected)))
+			}
+			result.Details = append(result.Details, c.Summary())
+		}
+		log <- result
+		done <- struct{}{}
+	}()
+
+	if !e.search(stop, &result, log) {
+		return
+	}
+
+	// accepted requests may still have lost some of their parts
+	for result.Dropped >= 0 && result.Dropped-result.Intact > 1 {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			return
+		}
+		if !e.try(result.Intact+(result.Dropped-result.Intact)/2, &result, log) {
+			return
+		}
+	}
+}
+
+// search looks for the largest accepted size, returns false if search was interrupted
+func (e *ProbeEmitter) search(stop chan struct{}, result *ProbeResult, log chan EmitterEvent) bool {
+
+	// growing phase
+	last := -1
+	for result.Rejected < 0 {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			return false
+		}
+		size, err := e.probe.Grow()
+		if err != nil {
+			log <- errors.New(fmt.Sprintf("Error: %s", err))
+			result.Err = err
+			return false
+		}
+		if size <= last {
+			// content doesn't grow anymore, so there is nothing to search for
+			return true
+		}
+		if e.options.MaxSize > 0 && size > e.options.MaxSize {
+			return true
+		}
+		last = size
+		if !e.try(size, result, log) {
+			return false
+		}
+	}
+
+	// bisection phase, it doesn't go below the smallest size probe can produce
+	low := func() int {
+		if p, ok := e.probe.(BoundedProbe); ok && result.Accepted < p.MinSize() {
+			return p.MinSize() - 1
+		}
+		return result.Accepted
+	}
+	for result.Rejected-low() > 1 {
+		if e.options.interrupted(stop, result.Requests) {
+			result.Interrupted = true
+			return false
+		}
+		if !e.try(low()+(result.Rejected-low())/2, result, log) {
+			return false
+		}
+	}
+
+	// retry at the last good size, flaky limits should not be reported as exact ones
+	if result.Accepted < 0 {
+		return true
+	}
+	if e.options.interrupted(stop, result.Requests) {
+		result.Interrupted = true
+		return false
+	}
+	accepted := result.Accepted
+	if !e.try(accepted, result, log) {
+		return false
+	}
+	result.Confirmed = result.Rejected != accepted
+	return true
+}
+
+// try sends request with probed value of given size and updates result with the verdict,
+// returns false if search can't be continued
+func (e *ProbeEmitter) try(size int, result *ProbeResult, log chan EmitterEvent) bool {
+
+	var verdict Verdict
+	var code int
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		verdict, code, err = e.send(size, log)
+		if err != nil {
+			log <- errors.New(fmt.Sprintf("Error: %s", err))
+			result.Err = err
+			return false
+		}
+		result.Requests++
+		if e.classifier.Meaning(verdict) != MeaningRetry || attempt >= e.classifier.Retries {
+			break
+		}
+	}
+
+	switch {
+	case verdict == VerdictDropped && (result.Dropped < 0 || size < result.Dropped):
+		result.Dropped = size
+	case verdict == VerdictOK && size > result.Intact:
+		result.Intact = size
+	}
+
+	if e.classifier.Meaning(verdict) == MeaningAccept {
+		if size > result.Accepted {
+			result.Accepted = size
+		}
+		return true
+	}
+
+	if result.Rejected < 0 || size < result.Rejected {
+		result.Rejected = size
+		result.Code = code
+		result.Verdict = verdict
+	}
+	return true
+}
+
+// send performs a single request and classifies it's outcome,
+// error is returned only if request could not be prepared
+func (e *ProbeEmitter) send(size int, log chan EmitterEvent) (Verdict, int, error) {
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	if err := e.prepare(req, size); err != nil {
+		return VerdictError, 0, err
+	}
+
+	start := time.Now()
+	timeout := e.options.RequestTimeout
+	if p, ok := e.probe.(SlowProbe); ok {
+		timeout += p.Duration(size)
+	}
+	if p, ok := e.probe.(PacedProbe); ok {
+		timeout += p.HeaderDuration(size)
+		// emitter sends requests one by one, so client can be set up for each of them
+		if c, ok := e.client.(*RawClient); ok {
+			c.HeaderDuration = p.HeaderDuration(size)
+		}
+	}
+	err := e.client.DoTimeout(req, resp, timeout)
+	verdict := e.classifier.Classify(resp, err)
+	if err != nil {
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return verdict, 0, nil
+	}
+	if p, ok := e.probe.(CheckingProbe); ok && verdict == VerdictOK {
+		verdict = p.Check(resp, size)
+	}
+
+	log <- LoadEmitterEvent{
+		Code:          resp.StatusCode(),
+		RequestTime:   time.Since(start),
+		RequestLength: resp.Header.ContentLength(),
+		Verdict:       verdict,
+	}
+	return verdict, resp.StatusCode(), nil
+}
+
+// prepare puts probed value of given size into request to destination
+func (e *ProbeEmitter) prepare(req *fasthttp.Request, size int) error {
+	req.SetRequestURI(e.options.Dest.String())
+	req.Header.SetMethod(e.options.Method)
+	setProxyAuthorization(req, e.proxy)
+	return e.probe.Prepare(req, size)
+}
+
+// headerListSize returns http/2 header list size of request with probed value of given size,
+// -1 if there is no such size
+func (e *ProbeEmitter) headerListSize(size int) int {
+	if size < 0 {
+		return -1
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	if err := e.prepare(req, size); err != nil {
+		return -1
+	}
+	return H2HeaderListSize(req)
+}
+
+func newHostClient(dest, proxy *url.URL) *fasthttp.HostClient {
+	// a single failed attempt is the answer we are looking for, so never retry
+	if proxy != nil {
+		return &fasthttp.HostClient{Addr: proxy.Host, MaxIdemponentCallAttempts: 1}
+	}
+	return &fasthttp.HostClient{
+		Addr:                      dest.Host,
+		IsTLS:                     dest.Scheme == "https",
+		MaxIdemponentCallAttempts: 1,
+	}
+}
+
+func setProxyAuthorization(req *fasthttp.Request, proxy *url.URL) {
+	if proxy == nil || proxy.User == nil {
+		return
+	}
+	req.Header.Set("Proxy-Authorization", proxyAuthorization(proxy))
+}
+
+// proxyAuthorization returns Proxy-Authorization header value with credentials of proxy url
+func proxyAuthorization(proxy *url.URL) string {
+	password, _ := proxy.User.Password()
+	credentials := proxy.User.Username() + ":" + password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
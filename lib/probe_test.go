@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// fakeProbe grows through given sizes and puts the size into request header
+type fakeProbe struct {
+	sizes   []int
+	next    int
+	growErr error
+	minSize int
+}
+
+func (p *fakeProbe) Name() string {
+	return "fake"
+}
+
+func (p *fakeProbe) Grow() (int, error) {
+	if p.growErr != nil && p.next > 0 {
+		return 0, p.growErr
+	}
+	if p.next >= len(p.sizes) {
+		return p.sizes[len(p.sizes)-1], nil
+	}
+	p.next++
+	return p.sizes[p.next-1], nil
+}
+
+func (p *fakeProbe) Prepare(req *fasthttp.Request, size int) error {
+	req.Header.Set("X-Size", strconv.Itoa(size))
+	return nil
+}
+
+// boundedProbe is fake probe which can't produce values smaller than minSize
+type boundedProbe struct {
+	fakeProbe
+}
+
+func (p *boundedProbe) MinSize() int {
+	return p.minSize
+}
+
+// fakeClient accepts sizes up to the limit, the limit itself is accepted only once if it's flaky.
+// The first attempts fail with transient code.
+type fakeClient struct {
+	limit     int
+	flaky     bool
+	transient int
+	// sizes requests were sent with
+	sizes []int
+}
+
+func (c *fakeClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	size, _ := strconv.Atoi(string(req.Header.Peek("X-Size")))
+	seen := 0
+	for _, s := range c.sizes {
+		if s == size {
+			seen++
+		}
+	}
+	c.sizes = append(c.sizes, size)
+	switch {
+	case c.transient > 0:
+		c.transient--
+		resp.SetStatusCode(fasthttp.StatusBadGateway)
+	case size > c.limit, c.flaky && size == c.limit && seen > 0:
+		resp.SetStatusCode(fasthttp.StatusRequestHeaderFieldsTooLarge)
+	default:
+		resp.SetStatusCode(fasthttp.StatusOK)
+	}
+	return nil
+}
+
+// powers returns powers of two from 1 up to max
+func powers(max int) []int {
+	var sizes []int
+	for size := 1; size <= max; size *= 2 {
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// runProbe runs probe emitter until it's done and returns it's result
+func runProbe(t *testing.T, options *ProbeEmitterOptions, probe Probe, client Client) ProbeResult {
+	options.Dest, _ = url.Parse("http://127.0.0.1/")
+	options.Method = fasthttp.MethodGet
+	e := NewProbeEmitter(options, probe, nil).(*ProbeEmitter)
+	e.client = client
+
+	stop := make(chan struct{})
+	done := make(chan struct{}, 1)
+	log := make(chan EmitterEvent, 10000)
+	e.Start(stop, done, log)
+	close(log)
+	for event := range log {
+		if result, ok := event.(ProbeResult); ok {
+			return result
+		}
+	}
+	t.Fatal("probe emitter sent no result")
+	return ProbeResult{}
+}
+
+func TestProbeEmitterSearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ProbeEmitterOptions
+		probe   Probe
+		client  *fakeClient
+		retry   bool
+		// expected result and limit description
+		accepted, rejected int
+		confirmed          bool
+		interrupted        bool
+		limit              string
+	}{
+		{
+			name: "exact limit", probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 1000},
+			accepted: 1000, rejected: 1001, confirmed: true, limit: "1000",
+		},
+		{
+			name: "limit at grown size", probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 512},
+			accepted: 512, rejected: 513, confirmed: true, limit: "512",
+		},
+		{
+			name: "limit below the first size", probe: &fakeProbe{sizes: []int{64, 128}}, client: &fakeClient{limit: 10},
+			accepted: 10, rejected: 11, confirmed: true, limit: "10",
+		},
+		{
+			name: "rejected from the first size", probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: -1},
+			accepted: -1, rejected: 0, limit: "<0",
+		},
+		{
+			name: "flaky limit", probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 1000, flaky: true},
+			accepted: 1000, rejected: 1000, limit: "~1000",
+		},
+		{
+			name: "no limit", probe: &fakeProbe{sizes: powers(1024)}, client: &fakeClient{limit: 1 << 20},
+			accepted: 1024, rejected: -1, limit: ">=1024",
+		},
+		{
+			name: "max size", options: ProbeEmitterOptions{MaxSize: 100}, probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 1000},
+			accepted: 64, rejected: -1, limit: ">=64",
+		},
+		{
+			name: "out of requests", options: ProbeEmitterOptions{Limit: 12}, probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 1000},
+			accepted: 768, rejected: 1024, interrupted: true, limit: "768..1024",
+		},
+		{
+			name: "retried verdict", probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 1000, transient: 2}, retry: true,
+			accepted: 1000, rejected: 1001, confirmed: true, limit: "1000",
+		},
+		{
+			name: "retried verdict out of retries", probe: &fakeProbe{sizes: powers(1 << 20)}, client: &fakeClient{limit: 1000, transient: 3}, retry: true,
+			accepted: 0, rejected: 1, confirmed: true, limit: "0",
+		},
+		{
+			name: "bounded probe", probe: &boundedProbe{fakeProbe{sizes: []int{64, 128}, minSize: 20}}, client: &fakeClient{limit: 10},
+			accepted: -1, rejected: 20, limit: "<20",
+		},
+	}
+	for _, tt := range tests {
+		options := tt.options
+		if tt.retry {
+			options.Classifier = NewClassifier()
+			options.Classifier.Set(VerdictBadGateway, MeaningRetry)
+		}
+		r := runProbe(t, &options, tt.probe, tt.client)
+		if r.Accepted != tt.accepted || r.Rejected != tt.rejected {
+			t.Errorf("%s: accepted %d, rejected %d, want %d and %d", tt.name, r.Accepted, r.Rejected, tt.accepted, tt.rejected)
+		}
+		if r.Confirmed != tt.confirmed || r.Interrupted != tt.interrupted || r.Err != nil {
+			t.Errorf("%s: confirmed %v, interrupted %v, error %v, want %v, %v and no error",
+				tt.name, r.Confirmed, r.Interrupted, r.Err, tt.confirmed, tt.interrupted)
+		}
+		if limit := r.Limit(); limit != tt.limit {
+			t.Errorf("%s: limit %q, want %q", tt.name, limit, tt.limit)
+		}
+		if r.Requests != len(tt.client.sizes) {
+			t.Errorf("%s: %d requests counted, %d sent", tt.name, r.Requests, len(tt.client.sizes))
+		}
+		if p, ok := tt.probe.(BoundedProbe); ok {
+			for _, size := range tt.client.sizes {
+				if size < p.MinSize() {
+					t.Errorf("%s: request of %d bytes is smaller than min size %d", tt.name, size, p.MinSize())
+				}
+			}
+		}
+	}
+}
+
+func TestProbeEmitterSearchFailure(t *testing.T) {
+	client := &fakeClient{limit: 1000}
+	r := runProbe(t, &ProbeEmitterOptions{}, &fakeProbe{sizes: powers(1 << 20), growErr: errors.New("no more content")}, client)
+	if r.Err == nil {
+		t.Fatal("grow error is not recorded")
+	}
+	if r.Accepted != 1 || r.Rejected != -1 || r.Confirmed {
+		t.Errorf("accepted %d, rejected %d, confirmed %v, want 1, -1 and not confirmed", r.Accepted, r.Rejected, r.Confirmed)
+	}
+	if limit := r.Limit(); limit != "failed" {
+		t.Errorf("limit %q, want \"failed\"", limit)
+	}
+}
@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+
+	"github.com/valyala/fasthttp"
+)
+
+// QueryCountProbe grows number of small k=v pairs in the query string of the destination uri
+type QueryCountProbe struct {
+	dest    *url.URL
+	counter *Counter
+	items   *items
+	// request uri length by parameters count
+	lengths map[int]int
+}
+
+func NewQueryCountProbe(dest *url.URL, counter *Counter, valueSize uint, rnd *rand.Rand) *QueryCountProbe {
+	return &QueryCountProbe{
+		dest:    dest,
+		counter: counter,
+		items:   &items{prefix: URIQueryName, valueSize: valueSize, mode: ModeAlnum, key: rnd.Uint64()},
+		lengths: make(map[int]int),
+	}
+}
+
+func (p *QueryCountProbe) Name() string {
+	return "query count"
+}
+
+func (p *QueryCountProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *QueryCountProbe) Prepare(req *fasthttp.Request, size int) error {
+	names, values := p.items.get(size)
+	query := []byte(p.dest.RawQuery)
+	for i := range names {
+		if len(query) > 0 {
+			query = append(query, '&')
+		}
+		query = append(query, names[i]...)
+		query = append(query, '=')
+		query = append(query, values[i]...)
+	}
+	u := *p.dest
+	u.RawQuery = string(query)
+	req.SetRequestURI(u.String())
+	p.lengths[size] = len(u.RequestURI())
+	return nil
+}
+
+// Check compares parameters sent with the ones received by fatty server
+func (p *QueryCountProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	echo := parseEcho(resp)
+	if echo == nil {
+		return VerdictOK
+	}
+	names, values := p.items.get(size)
+	for i := range names {
+		if length, ok := echo.Query[names[i]]; !ok || length != len(values[i]) {
+			return VerdictDropped
+		}
+	}
+	return VerdictOK
+}
+
+func (p *QueryCountProbe) Report(result *ProbeResult) {
+	result.Unit = "parameters"
+	if length, ok := p.lengths[result.Accepted]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("request uri at max count: %d bytes", length))
+	}
+}
+
+var (
+	_ Probe          = (*QueryCountProbe)(nil)
+	_ CheckingProbe  = (*QueryCountProbe)(nil)
+	_ ReportingProbe = (*QueryCountProbe)(nil)
+)
@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Client performs a single request, it's implemented by fasthttp.HostClient
+// and by RawClient for requests fasthttp can't produce
+type Client interface {
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+}
+
+// RawClient writes every request over a new connection by itself. Streamed request body
+// is sent as soon as it's read, or with chunked transfer encoding in chunks of ChunkSize
+// bytes if it's length is unknown
+type RawClient struct {
+	Addr      string
+	IsTLS     bool
+	ChunkSize int
+	// Header lines are spread evenly over this time, they are sent at once if it's 0
+	HeaderDuration time.Duration
+}
+
+func NewRawClient(dest, proxy *url.URL) *RawClient {
+	if proxy != nil {
+		return &RawClient{Addr: addrWithPort(proxy)}
+	}
+	return &RawClient{Addr: addrWithPort(dest), IsTLS: dest.Scheme == "https"}
+}
+
+func (c *RawClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	conn, err := c.Dial(timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.DoTimeout(req, resp, timeout)
+}
+
+// Dial opens connection several requests can be sent over
+func (c *RawClient) Dial(timeout time.Duration) (*RawConn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if c.IsTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.Addr, &tls.Config{InsecureSkipVerify: true})
+	} else {
+		conn, err = dialer.Dial("tcp", c.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RawConn{conn: conn, client: c, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}, nil
+}
+
+// RawConn is a connection opened by RawClient, requests are written to it
+// in the same way RawClient writes them
+type RawConn struct {
+	conn   net.Conn
+	client *RawClient
+
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (c *RawConn) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	if timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := c.WriteRequest(req); err != nil {
+		return err
+	}
+	return c.ReadResponse(resp)
+}
+
+// WriteRequest sends request without waiting for response
+func (c *RawConn) WriteRequest(req *fasthttp.Request) error {
+	if err := c.client.write(c.w, req); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// WriteHeader sends only request header, request stays in flight until it's body is sent
+func (c *RawConn) WriteHeader(req *fasthttp.Request) error {
+	prepareRequest(req)
+	if _, err := c.w.Write(req.Header.Header()); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// ReadResponse reads the next response
+func (c *RawConn) ReadResponse(resp *fasthttp.Response) error {
+	return resp.Read(c.r)
+}
+
+// SetDeadline sets deadline of all the following reads and writes
+func (c *RawConn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *RawConn) Close() error {
+	return c.conn.Close()
+}
+
+// ErrIdleData is returned by WaitClose if the server sends anything to idle connection,
+// the data is left unread
+var ErrIdleData = errors.New("Server sent data to idle connection")
+
+// WaitClose waits until the server closes idle connection and returns how long it waited.
+// Only eof or reset count as closing, error is returned if connection isn't closed in time,
+// is closed locally or the server sends anything
+func (c *RawConn) WaitClose(timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	c.conn.SetReadDeadline(start.Add(timeout))
+	_, err := c.r.Peek(1)
+	switch {
+	case err == nil:
+		return time.Since(start), ErrIdleData
+	case classifyError(err) != VerdictReset:
+		return time.Since(start), err
+	}
+	return time.Since(start), nil
+}
+
+// prepareRequest fills request line and host header from request uri
+func prepareRequest(req *fasthttp.Request) {
+	uri := req.URI()
+	if len(req.Header.Host()) == 0 {
+		req.Header.SetHostBytes(uri.Host())
+	}
+	req.Header.SetRequestURIBytes(uri.RequestURI())
+}
+
+func (c *RawClient) write(w *bufio.Writer, req *fasthttp.Request) error {
+	prepareRequest(req)
+
+	body := req.BodyStream()
+	if body == nil && len(req.Body()) > 0 {
+		req.Header.SetContentLength(len(req.Body()))
+	}
+	if err := c.writeHeader(w, req.Header.Header()); err != nil {
+		return err
+	}
+	if body == nil {
+		_, err := w.Write(req.Body())
+		return err
+	}
+	if req.Header.ContentLength() >= 0 {
+		return writeFlushing(w, body)
+	}
+	return writeChunks(w, body, c.ChunkSize)
+}
+
+// writeHeader sends request line at once and every next line of the header block
+// after a pause, so the last empty line is sent when HeaderDuration is over
+func (c *RawClient) writeHeader(w *bufio.Writer, header []byte) error {
+	if c.HeaderDuration <= 0 {
+		_, err := w.Write(header)
+		return err
+	}
+	lines := bytes.SplitAfter(header, []byte("\r\n"))
+	// the last one is empty, since header block ends with crlf
+	lines = lines[:len(lines)-1]
+	pause := c.HeaderDuration / time.Duration(len(lines)-1)
+	for i, line := range lines {
+		if i > 0 {
+			time.Sleep(pause)
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFlushing sends every piece of body as soon as it's read
+func writeFlushing(w *bufio.Writer, body io.Reader) error {
+	buf := make([]byte, 4096)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if werr := w.Flush(); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeChunks sends body in chunks of exactly size bytes, except the last one
+func writeChunks(w *bufio.Writer, body io.Reader, size int) error {
+	if size <= 0 {
+		size = 4096
+	}
+	chunk := make([]byte, size)
+	for {
+		n, err := io.ReadFull(body, chunk)
+		if n > 0 {
+			if _, werr := fmt.Fprintf(w, "%x\r\n", n); werr != nil {
+				return werr
+			}
+			if _, werr := w.Write(chunk[:n]); werr != nil {
+				return werr
+			}
+			if _, werr := w.WriteString("\r\n"); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.WriteString("0\r\n\r\n")
+	return err
+}
+
+// addrWithPort returns host:port of the url, adding default port of it's scheme
+func addrWithPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+var _ Client = (*RawClient)(nil)
@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Seeder hands out independent random sources derived from a single run seed.
+// Sources are derived in order they are requested, so generators created
+// in the same order produce the same bytes on every run with the same seed.
+type Seeder struct {
+	seed  int64
+	count int64
+	mutex *sync.Mutex
+}
+
+// NewSeeder creates seeder from the seed, zero seed is replaced with a random one
+func NewSeeder(seed int64) *Seeder {
+	for seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Seeder{seed: seed, mutex: &sync.Mutex{}}
+}
+
+// Seed returns run seed to replay the run with
+func (s *Seeder) Seed() int64 {
+	return s.seed
+}
+
+// Rand returns the next random source derived from run seed
+func (s *Seeder) Rand() *rand.Rand {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	return rand.New(rand.NewSource(int64(splitmix64(uint64(s.seed) ^ uint64(s.count)*0x9E3779B97F4A7C15))))
+}
+
+// splitmix64 scrambles x, so sources of close seeds and counters don't share their streams
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ x>>30) * 0xBF58476D1CE4E5B9
+	x = (x ^ x>>27) * 0x94D049BB133111EB
+	return x ^ x>>31
+}
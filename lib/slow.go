@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SlowBodyProbe sends body of fixed size evenly spread over growing time,
+// so the rate gets lower until the server stops waiting for the body.
+// Probed size is the time of sending body in milliseconds.
+type SlowBodyProbe struct {
+	body    []byte
+	counter *Counter
+}
+
+func NewSlowBodyProbe(body []byte, counter *Counter) *SlowBodyProbe {
+	return &SlowBodyProbe{body: body, counter: counter}
+}
+
+func (p *SlowBodyProbe) Name() string {
+	return fmt.Sprintf("slow body (%d bytes)", len(p.body))
+}
+
+func (p *SlowBodyProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *SlowBodyProbe) Prepare(req *fasthttp.Request, size int) error {
+	req.SetBodyStream(&slowReader{body: p.body, duration: p.Duration(size)}, len(p.body))
+	return nil
+}
+
+func (p *SlowBodyProbe) Duration(size int) time.Duration {
+	return time.Duration(size) * time.Millisecond
+}
+
+// Check makes sure fatty server has read the whole body before answering
+func (p *SlowBodyProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	echo := parseEcho(resp)
+	if echo == nil || echo.Body == len(p.body) {
+		return VerdictOK
+	}
+	return VerdictDropped
+}
+
+func (p *SlowBodyProbe) Report(result *ProbeResult) {
+	result.Unit = "ms"
+	if result.Accepted > 0 {
+		rate := float64(len(p.body)) / p.Duration(result.Accepted).Seconds()
+		result.Details = append(result.Details, fmt.Sprintf("body read for up to %s, min accepted rate: %.1f bytes/sec",
+			p.Duration(result.Accepted), rate))
+	}
+	if result.Rejected > 0 {
+		rate := float64(len(p.body)) / p.Duration(result.Rejected).Seconds()
+		result.Details = append(result.Details, fmt.Sprintf("aborted at %.1f bytes/sec", rate))
+	}
+}
+
+var (
+	_ Probe          = (*SlowBodyProbe)(nil)
+	_ SlowProbe      = (*SlowBodyProbe)(nil)
+	_ CheckingProbe  = (*SlowBodyProbe)(nil)
+	_ ReportingProbe = (*SlowBodyProbe)(nil)
+)
+
+// slowReader returns body bytes not earlier than they are due to be sent,
+// the last one is returned when the duration is over
+type slowReader struct {
+	body     []byte
+	duration time.Duration
+
+	start time.Time
+	pos   int
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.body) {
+		return 0, io.EOF
+	}
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	// time the next byte is due
+	due := r.start.Add(time.Duration(int64(r.duration) * int64(r.pos+1) / int64(len(r.body))))
+	if wait := time.Until(due); wait > 0 {
+		time.Sleep(wait)
+	}
+	// bytes which became due while sleeping
+	elapsed := time.Since(r.start)
+	n := len(r.body)
+	if elapsed < r.duration {
+		n = int(int64(len(r.body)) * int64(elapsed) / int64(r.duration))
+	}
+	if n <= r.pos {
+		n = r.pos + 1
+	}
+	copied := copy(p, r.body[r.pos:n])
+	r.pos += copied
+	return copied, nil
+}
+
+// SlowHeaderProbe sends header lines with growing pauses between them until the server
+// stops waiting for the header block. Probed size is the time of sending header block
+// in milliseconds.
+type SlowHeaderProbe struct {
+	lines   int
+	counter *Counter
+	items   *items
+}
+
+func NewSlowHeaderProbe(lines uint, counter *Counter, rnd *rand.Rand) *SlowHeaderProbe {
+	return &SlowHeaderProbe{
+		lines:   int(lines),
+		counter: counter,
+		items:   &items{prefix: "X-Fatty-", valueSize: 8, mode: ModeAlnum, key: rnd.Uint64()},
+	}
+}
+
+func (p *SlowHeaderProbe) Name() string {
+	return fmt.Sprintf("slow header (%d lines)", p.lines)
+}
+
+func (p *SlowHeaderProbe) Grow() (int, error) {
+	return p.counter.Grow(), nil
+}
+
+func (p *SlowHeaderProbe) Prepare(req *fasthttp.Request, size int) error {
+	names, values := p.items.get(p.lines)
+	for i := range names {
+		req.Header.SetBytesV(names[i], values[i])
+	}
+	return nil
+}
+
+func (p *SlowHeaderProbe) HeaderDuration(size int) time.Duration {
+	return time.Duration(size) * time.Millisecond
+}
+
+func (p *SlowHeaderProbe) Report(result *ProbeResult) {
+	result.Unit = "ms"
+	if result.Accepted > 0 {
+		result.Details = append(result.Details, fmt.Sprintf("header block read for up to %s",
+			p.HeaderDuration(result.Accepted)))
+	}
+}
+
+var (
+	_ Probe          = (*SlowHeaderProbe)(nil)
+	_ PacedProbe     = (*SlowHeaderProbe)(nil)
+	_ ReportingProbe = (*SlowHeaderProbe)(nil)
+)
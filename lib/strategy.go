@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Strategy decides how content grows
+type Strategy interface {
+	// Next returns size following the current one, returning the same size stops growth
+	Next(size uint) uint
+	// Prev returns size preceding the current one, returning the same size stops shrinking
+	Prev(size uint) uint
+}
+
+// LinearStrategy grows content by a constant number of bytes
+type LinearStrategy struct {
+	Step uint
+}
+
+func (s *LinearStrategy) Next(size uint) uint {
+	return size + s.Step
+}
+
+func (s *LinearStrategy) Prev(size uint) uint {
+	if size < s.Step {
+		return 0
+	}
+	return size - s.Step
+}
+
+// ExponentialStrategy multiplies content size by a constant factor
+type ExponentialStrategy struct {
+	Factor uint
+}
+
+func (s *ExponentialStrategy) Next(size uint) uint {
+	if size == 0 && s.Factor > 1 {
+		return 1
+	}
+	return size * s.Factor
+}
+
+func (s *ExponentialStrategy) Prev(size uint) uint {
+	if s.Factor < 2 {
+		return size
+	}
+	return size / s.Factor
+}
+
+// FibonacciStrategy grows content by the previous size: s, 2s, 3s, 5s, 8s...
+type FibonacciStrategy struct {
+	prev uint
+}
+
+func (s *FibonacciStrategy) Next(size uint) uint {
+	if s.prev == 0 {
+		s.prev = size
+		if s.prev == 0 {
+			s.prev = 1
+		}
+	}
+	next := size + s.prev
+	s.prev = size
+	return next
+}
+
+func (s *FibonacciStrategy) Prev(size uint) uint {
+	if s.prev == 0 || s.prev >= size {
+		// size was set outside of the sequence, fall back to golden ratio
+		s.prev = 0
+		return size * 618 / 1000
+	}
+	prev := s.prev
+	s.prev = size - prev
+	return prev
+}
+
+// ScheduleStrategy walks through explicit list of sizes
+type ScheduleStrategy struct {
+	sizes []uint
+}
+
+func NewScheduleStrategy(sizes []uint) *ScheduleStrategy {
+	sorted := append([]uint(nil), sizes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &ScheduleStrategy{sizes: sorted}
+}
+
+// NewScheduleStrategyFromFile reads sizes separated by spaces, commas or new lines,
+// lines starting with # are ignored
+func NewScheduleStrategyFromFile(f string) (*ScheduleStrategy, error) {
+	content, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	var sizes []uint
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			size, err := strconv.ParseUint(field, 10, 0)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid size in schedule file %s: %s", f, field))
+			}
+			sizes = append(sizes, uint(size))
+		}
+	}
+	if len(sizes) == 0 {
+		return nil, errors.New(fmt.Sprintf("Schedule file %s contains no sizes", f))
+	}
+	return NewScheduleStrategy(sizes), nil
+}
+
+func (s *ScheduleStrategy) Next(size uint) uint {
+	for _, next := range s.sizes {
+		if next > size {
+			return next
+		}
+	}
+	return size
+}
+
+func (s *ScheduleStrategy) Prev(size uint) uint {
+	for i := len(s.sizes) - 1; i >= 0; i-- {
+		if s.sizes[i] < size {
+			return s.sizes[i]
+		}
+	}
+	return size
+}
+
+var (
+	_ Strategy = (*LinearStrategy)(nil)
+	_ Strategy = (*ExponentialStrategy)(nil)
+	_ Strategy = (*FibonacciStrategy)(nil)
+	_ Strategy = (*ScheduleStrategy)(nil)
+)
+
+// NewStrategy builds strategy by it's name: linear, expo, fibonacci or schedule.
+// Empty name keeps the old behaviour: linear if inc is set, exponential otherwise.
+func NewStrategy(name string, inc, multi uint, scheduleFile string) (Strategy, error) {
+	switch name {
+	case "":
+		if inc > 0 {
+			return &LinearStrategy{Step: inc}, nil
+		}
+		return &ExponentialStrategy{Factor: multi}, nil
+	case "linear":
+		return &LinearStrategy{Step: inc}, nil
+	case "expo":
+		return &ExponentialStrategy{Factor: multi}, nil
+	case "fibonacci":
+		return &FibonacciStrategy{}, nil
+	case "schedule":
+		if scheduleFile == "" {
+			return nil, errors.New("Schedule strategy requires a schedule file")
+		}
+		return NewScheduleStrategyFromFile(scheduleFile)
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown growth strategy: %s", name))
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// walk applies step to size n times and returns every size on the way
+func walk(size uint, n int, step func(uint) uint) []uint {
+	var sizes []uint
+	for i := 0; i < n; i++ {
+		size = step(size)
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+func TestStrategyNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		start    uint
+		want     []uint
+	}{
+		{"linear", &LinearStrategy{Step: 3}, 5, []uint{8, 11, 14}},
+		{"linear from zero", &LinearStrategy{Step: 10}, 0, []uint{10, 20, 30}},
+		{"linear without step", &LinearStrategy{}, 5, []uint{5, 5, 5}},
+		{"expo", &ExponentialStrategy{Factor: 2}, 3, []uint{6, 12, 24}},
+		{"expo from zero", &ExponentialStrategy{Factor: 2}, 0, []uint{1, 2, 4}},
+		{"expo without factor", &ExponentialStrategy{Factor: 1}, 5, []uint{5, 5, 5}},
+		{"fibonacci", &FibonacciStrategy{}, 1, []uint{2, 3, 5, 8, 13}},
+		{"fibonacci from size", &FibonacciStrategy{}, 10, []uint{20, 30, 50, 80}},
+		{"fibonacci from zero", &FibonacciStrategy{}, 0, []uint{1, 2, 3, 5}},
+		{"schedule", NewScheduleStrategy([]uint{100, 10, 1000}), 0, []uint{10, 100, 1000}},
+		{"schedule between sizes", NewScheduleStrategy([]uint{100, 10, 1000}), 50, []uint{100, 1000}},
+		{"schedule exhausted", NewScheduleStrategy([]uint{100, 10, 1000}), 100, []uint{1000, 1000, 1000}},
+		{"schedule beyond the last size", NewScheduleStrategy([]uint{10}), 20, []uint{20}},
+	}
+	for _, tt := range tests {
+		if got := walk(tt.start, len(tt.want), tt.strategy.Next); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Next from %d = %v, want %v", tt.name, tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestStrategyPrev(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		start    uint
+		want     []uint
+	}{
+		{"linear", &LinearStrategy{Step: 3}, 8, []uint{5, 2, 0, 0}},
+		{"expo", &ExponentialStrategy{Factor: 2}, 8, []uint{4, 2, 1, 0, 0}},
+		{"expo without factor", &ExponentialStrategy{Factor: 1}, 8, []uint{8, 8}},
+		{"fibonacci outside of sequence", &FibonacciStrategy{}, 1000, []uint{618, 381}},
+		{"schedule", NewScheduleStrategy([]uint{100, 10, 1000}), 1000, []uint{100, 10}},
+		{"schedule between sizes", NewScheduleStrategy([]uint{100, 10, 1000}), 50, []uint{10}},
+		{"schedule exhausted", NewScheduleStrategy([]uint{100, 10, 1000}), 10, []uint{10, 10}},
+	}
+	for _, tt := range tests {
+		if got := walk(tt.start, len(tt.want), tt.strategy.Prev); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Prev from %d = %v, want %v", tt.name, tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestFibonacciStrategyPrevAfterNext(t *testing.T) {
+	s := &FibonacciStrategy{}
+	size := uint(1)
+	for i := 0; i < 5; i++ {
+		size = s.Next(size)
+	}
+	if size != 13 {
+		t.Fatalf("Next 5 times from 1 = %d, want 13", size)
+	}
+	if got, want := walk(size, 4, s.Prev), []uint{8, 5, 3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Prev from 13 = %v, want %v", got, want)
+	}
+}
+
+func TestNewScheduleStrategyFromFile(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []uint
+		err     bool
+	}{
+		{"10 100\n1000", []uint{10, 100, 1000}, false},
+		{"# sizes\n1000,10\r\n\t100\n", []uint{10, 100, 1000}, false},
+		{"# nothing\n", nil, true},
+		{"10 ten", nil, true},
+		{"-1", nil, true},
+	}
+	for _, tt := range tests {
+		f, err := ioutil.TempFile("", "fatty-schedule")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(tt.content)
+		f.Close()
+		s, err := NewScheduleStrategyFromFile(f.Name())
+		os.Remove(f.Name())
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got sizes %v", tt.content, s.sizes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.content, err)
+			continue
+		}
+		if !reflect.DeepEqual(s.sizes, tt.want) {
+			t.Errorf("%q: sizes = %v, want %v", tt.content, s.sizes, tt.want)
+		}
+	}
+}
+
+func TestNewStrategy(t *testing.T) {
+	tests := []struct {
+		name       string
+		inc, multi uint
+		want       Strategy
+		wantErr    bool
+	}{
+		{name: "", inc: 5, multi: 2, want: &LinearStrategy{Step: 5}},
+		{name: "", inc: 0, multi: 2, want: &ExponentialStrategy{Factor: 2}},
+		{name: "linear", inc: 5, multi: 2, want: &LinearStrategy{Step: 5}},
+		{name: "expo", inc: 5, multi: 3, want: &ExponentialStrategy{Factor: 3}},
+		{name: "fibonacci", want: &FibonacciStrategy{}},
+		{name: "schedule", wantErr: true},
+		{name: "random", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NewStrategy(tt.name, tt.inc, tt.multi, "")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewStrategy(%q): expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewStrategy(%q): unexpected error: %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewStrategy(%q, %d, %d) = %#v, want %#v", tt.name, tt.inc, tt.multi, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+)
+
+// size of random block stream payload is cycled from
+const streamBlockSize = 64 * 1024
+
+type StreamableContent interface {
+	// tries to grow payload size according to content settings
+	Grow() (uint, error)
+	// changes payload size to exactly n bytes
+	SetSize(n uint) (uint, error)
+	// tries to step payload size back according to content settings
+	Shrink() (uint, error)
+	// returns reader producing current payload and it's length
+	Reader() (io.Reader, int)
+}
+
+// StreamContent never holds the whole payload, bytes are produced lazily
+// while request is being written, so memory usage doesn't depend on it's size.
+type StreamContent struct {
+	size     uint
+	strategy Strategy
+	mode     Mode
+	block    []byte
+	started  bool
+
+	mutex *sync.Mutex
+}
+
+// NewStreamContent creates content of initial size s which grows according to strategy,
+// payload is generated in given mode from the key drawn from rnd
+func NewStreamContent(s uint, strategy Strategy, mode Mode, rnd *rand.Rand) *StreamContent {
+	gen := mode.generator()
+	// block is a prefix followed by whole units, so payload structure survives block cycling
+	block := make([]byte, gen.prefix+(streamBlockSize-gen.prefix)/gen.period*gen.period)
+	gen.fill(rnd.Uint64(), block, 0)
+	return &StreamContent{size: s, strategy: strategy, mode: mode, block: block, mutex: &sync.Mutex{}}
+}
+
+func (c *StreamContent) Grow() (uint, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.started {
+		// first request is sent with the initial size
+		c.started = true
+	} else if next := c.strategy.Next(c.size); next > c.size {
+		c.size = next
+	}
+	return c.size, nil
+}
+
+func (c *StreamContent) SetSize(n uint) (uint, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.size = n
+	c.started = true
+	return c.size, nil
+}
+
+func (c *StreamContent) Shrink() (uint, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if prev := c.strategy.Prev(c.size); prev < c.size {
+		c.size = prev
+	}
+	return c.size, nil
+}
+
+func (c *StreamContent) Reader() (io.Reader, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	gen := c.mode.generator()
+	r := &blockReader{block: c.block, prefix: gen.prefix, size: uint64(c.size)}
+	// the last bytes are sealed beforehand, so payload is valid as a whole
+	r.tail = make([]byte, minUint(c.size, gen.tailSize))
+	from := uint64(c.size) - uint64(len(r.tail))
+	for i := range r.tail {
+		r.tail[i] = c.block[r.offset(from+uint64(i))]
+	}
+	gen.seal(r.tail, c.size)
+	return r, int(c.size)
+}
+
+func (c *StreamContent) Mode() Mode {
+	return c.mode
+}
+
+var (
+	_ StreamableContent = (*StreamContent)(nil)
+	_ ModalContent      = (*StreamContent)(nil)
+)
+
+// blockReader cycles through the block until it produces the required number of bytes
+type blockReader struct {
+	block  []byte
+	prefix uint
+	tail   []byte
+
+	pos, size uint64
+}
+
+func (r *blockReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	body := r.size - uint64(len(r.tail))
+	n := 0
+	for n < len(p) && r.pos < r.size {
+		var chunk []byte
+		if r.pos >= body {
+			chunk = r.tail[r.pos-body:]
+		} else {
+			chunk = r.block[r.offset(r.pos):]
+			if left := body - r.pos; uint64(len(chunk)) > left {
+				chunk = chunk[:left]
+			}
+		}
+		copied := copy(p[n:], chunk)
+		n += copied
+		r.pos += uint64(copied)
+	}
+	return n, nil
+}
+
+// offset returns position of payload byte in the block
+func (r *blockReader) offset(pos uint64) uint64 {
+	if pos < uint64(len(r.block)) {
+		return pos
+	}
+	cycle := uint64(len(r.block)) - uint64(r.prefix)
+	return uint64(r.prefix) + (pos-uint64(r.prefix))%cycle
+}
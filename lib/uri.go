@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// URIPart is a part of request uri grown by uri probe
+type URIPart string
+
+const (
+	URIPath  URIPart = "path"
+	URIQuery URIPart = "query"
+)
+
+// name of query parameter grown by query probe
+const URIQueryName = "fatty"
+
+// URIProbe grows either path or query string of the destination uri
+type URIProbe struct {
+	dest    *url.URL
+	part    URIPart
+	content GrowableContent
+	// request uri length by probed size
+	lengths map[int]int
+}
+
+func NewURIProbe(dest *url.URL, part URIPart, content GrowableContent) *URIProbe {
+	return &URIProbe{dest: dest, part: part, content: content, lengths: make(map[int]int)}
+}
+
+func (p *URIProbe) Name() string {
+	return "uri " + string(p.part)
+}
+
+func (p *URIProbe) Grow() (int, error) {
+	payload, err := p.content.Grow()
+	return len(payload), err
+}
+
+func (p *URIProbe) Prepare(req *fasthttp.Request, size int) error {
+	payload, err := p.content.SetSize(uint(size))
+	if err != nil {
+		return err
+	}
+	u := *p.dest
+	switch p.part {
+	case URIPath:
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + string(payload)
+		u.RawPath = ""
+	case URIQuery:
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += URIQueryName + "=" + string(payload)
+	}
+	req.SetRequestURI(u.String())
+	p.lengths[size] = len(u.RequestURI())
+	return nil
+}
+
+func (p *URIProbe) Report(result *ProbeResult) {
+	if length, ok := p.lengths[result.Accepted]; ok {
+		result.Details = append(result.Details, fmt.Sprintf("max request uri length: %d bytes", length))
+	}
+}
+
+var (
+	_ Probe          = (*URIProbe)(nil)
+	_ ReportingProbe = (*URIProbe)(nil)
+)
@@ -0,0 +1,266 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/valyala/fasthttp"
+)
+
+// WebSocketClient sends request body as a single message over a new WebSocket connection
+// and puts the message echoed back into response body. Message is split into frames
+// of FrameSize bytes, it's sent in a single frame if FrameSize is 0.
+type WebSocketClient struct {
+	URL       string
+	Proxy     *url.URL
+	FrameSize int
+}
+
+// NewWebSocketClient returns client which upgrades connection to destination, through proxy if it's set
+func NewWebSocketClient(dest, proxy *url.URL) *WebSocketClient {
+	u := *dest
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	return &WebSocketClient{URL: u.String(), Proxy: proxy}
+}
+
+// Dial opens a new WebSocket connection, when handshake is rejected
+// the status code of the response is returned along with the error
+func (c *WebSocketClient) Dial(timeout time.Duration, writeBufferSize int) (*websocket.Conn, int, error) {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: timeout,
+		WriteBufferSize:  writeBufferSize,
+	}
+	if c.Proxy != nil {
+		dialer.NetDialContext = c.dialProxy
+	}
+	conn, resp, err := dialer.Dial(c.URL, nil)
+	if err != nil {
+		var connectErr *ConnectError
+		if errors.As(err, &connectErr) {
+			return nil, connectErr.Code, err
+		}
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			return nil, resp.StatusCode, err
+		}
+		return nil, 0, err
+	}
+	return conn, http.StatusSwitchingProtocols, nil
+}
+
+// ConnectError is returned when proxy refuses to open a tunnel
+type ConnectError struct {
+	Code   int
+	Status string
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("Proxy refused to connect: %s", e.Status)
+}
+
+// dialProxy opens a tunnel to addr through proxy with CONNECT request,
+// so the proxy sees credentials and it's response code is known
+func (c *WebSocketClient) dialProxy(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addrWithPort(c.Proxy))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if c.Proxy.User != nil {
+		req.Header.Set("Proxy-Authorization", proxyAuthorization(c.Proxy))
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// the server says nothing until handshake, so nothing is left in the buffer
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &ConnectError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (c *WebSocketClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	message := req.Body()
+	frameSize := c.FrameSize
+	if frameSize <= 0 {
+		frameSize = len(message)
+	}
+	conn, code, err := c.Dial(timeout, frameSize)
+	if code >= 300 && err != nil {
+		// rejected handshake is classified by it's status code
+		resp.SetStatusCode(code)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+
+	if err = conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+		return err
+	}
+	_, echo, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	resp.SetStatusCode(fasthttp.StatusOK)
+	resp.SetBody(echo)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return nil
+}
+
+// WebSocketProbe grows size of a single message, sent either in frames of fixed size
+// or in a single frame
+type WebSocketProbe struct {
+	content   GrowableContent
+	frameSize int
+	// the last sent message
+	message []byte
+}
+
+// NewWebSocketMessageProbe returns probe of message size, message is sent in frames of frameSize bytes
+func NewWebSocketMessageProbe(content GrowableContent, frameSize int) *WebSocketProbe {
+	return &WebSocketProbe{content: content, frameSize: frameSize}
+}
+
+// NewWebSocketFrameProbe returns probe of frame size, the whole message is sent in a single frame
+func NewWebSocketFrameProbe(content GrowableContent) *WebSocketProbe {
+	return &WebSocketProbe{content: content}
+}
+
+func (p *WebSocketProbe) Name() string {
+	if p.frameSize > 0 {
+		return "websocket message"
+	}
+	return "websocket frame"
+}
+
+func (p *WebSocketProbe) Grow() (int, error) {
+	payload, err := p.content.Grow()
+	return len(payload), err
+}
+
+func (p *WebSocketProbe) Prepare(req *fasthttp.Request, size int) error {
+	payload, err := p.content.SetSize(uint(size))
+	if err != nil {
+		return err
+	}
+	req.SetBodyRaw(payload)
+	p.message = payload
+	return nil
+}
+
+func (p *WebSocketProbe) Client(dest, proxy *url.URL) Client {
+	client := NewWebSocketClient(dest, proxy)
+	client.FrameSize = p.frameSize
+	return client
+}
+
+func (p *WebSocketProbe) Report(result *ProbeResult) {
+	if p.frameSize > 0 {
+		result.Details = append(result.Details, fmt.Sprintf("message is sent in frames of %d bytes", p.frameSize))
+	}
+}
+
+// Check makes sure the message is echoed back in full
+func (p *WebSocketProbe) Check(resp *fasthttp.Response, size int) Verdict {
+	if !bytes.Equal(resp.Body(), p.message) {
+		return VerdictTruncated
+	}
+	return VerdictOK
+}
+
+func (p *WebSocketProbe) Mode() Mode {
+	if m, ok := p.content.(ModalContent); ok {
+		return m.Mode()
+	}
+	return ""
+}
+
+var (
+	_ Probe          = (*WebSocketProbe)(nil)
+	_ ClientProbe    = (*WebSocketProbe)(nil)
+	_ CheckingProbe  = (*WebSocketProbe)(nil)
+	_ ReportingProbe = (*WebSocketProbe)(nil)
+)
+
+// NewWebSocketConnsEmitter opens WebSocket connections one by one and keeps them open,
+// every one of them must echo a message
+func NewWebSocketConnsEmitter(options *ProbeEmitterOptions, connsOptions *ConnsOptions, proxy *url.URL) Emitter {
+	emitter := newConnsEmitter("websocket connections", options, connsOptions, proxy)
+	client := NewWebSocketClient(options.Dest, proxy)
+	emitter.open = func(log chan EmitterEvent) (io.Closer, Verdict, int, time.Duration) {
+		return openWebSocket(client, options.RequestTimeout, log)
+	}
+	return emitter
+}
+
+// openWebSocket upgrades a new connection and checks a message is echoed over it
+func openWebSocket(client *WebSocketClient, timeout time.Duration, log chan EmitterEvent) (io.Closer, Verdict, int, time.Duration) {
+
+	start := time.Now()
+	conn, code, err := client.Dial(timeout, 0)
+	if err != nil && code != 0 {
+		verdict := classifyCode(code)
+		if verdict == VerdictOK {
+			// e.g. the server has answered with a regular page
+			verdict = VerdictError
+		}
+		log <- LoadEmitterEvent{Code: code, RequestTime: time.Since(start), Verdict: verdict}
+		return nil, verdict, code, 0
+	}
+	if err != nil {
+		if localLimit(err, log) {
+			return nil, VerdictError, 0, 0
+		}
+		verdict := classifyError(err)
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return nil, verdict, 0, 0
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("fatty")); err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	latency := time.Since(start)
+	if err != nil {
+		verdict := classifyError(err)
+		log <- &ClassifiedError{Verdict: verdict, Err: err}
+		return conn, verdict, 0, latency
+	}
+	conn.SetReadDeadline(time.Time{})
+	conn.SetWriteDeadline(time.Time{})
+	log <- LoadEmitterEvent{Code: code, RequestTime: latency, Verdict: VerdictOK}
+	return conn, VerdictOK, code, latency
+}